@@ -0,0 +1,56 @@
+package heapescapeanalysis
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+// compareAllocsRuns is the number of runs CompareAllocs averages over.
+const compareAllocsRuns = 100
+
+// CompareAllocs measures each variant with testing.AllocsPerRun and returns
+// a plain-text table of allocations per run, one row per variant sorted by
+// name. The last column is the difference from the variant with the fewest
+// allocations, plus the ratio when that variant allocates at all:
+//
+//	append: allocations per run
+//	VARIANT   ALLOCS/RUN  VS FEWEST
+//	grow      1           +0
+//	repeated  9           +8 (9.00x)
+//
+// testing.AllocsPerRun works outside tests too, so the table can be printed
+// from examples or a command. Like AllocsPerRun, CompareAllocs must not be
+// called while other goroutines are allocating.
+func CompareAllocs(name string, variants map[string]func()) string {
+	names := make([]string, 0, len(variants))
+	for v := range variants {
+		names = append(names, v)
+	}
+	slices.Sort(names)
+
+	allocs := make(map[string]float64, len(names))
+	fewest := -1.0
+	for _, v := range names {
+		allocs[v] = testing.AllocsPerRun(compareAllocsRuns, variants[v])
+		if fewest < 0 || allocs[v] < fewest {
+			fewest = allocs[v]
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: allocations per run\n", name)
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VARIANT\tALLOCS/RUN\tVS FEWEST")
+	for _, v := range names {
+		delta := fmt.Sprintf("%+g", allocs[v]-fewest)
+		if fewest > 0 && allocs[v] != fewest {
+			delta += fmt.Sprintf(" (%.2fx)", allocs[v]/fewest)
+		}
+		fmt.Fprintf(tw, "%s\t%g\t%s\n", v, allocs[v], delta)
+	}
+	tw.Flush()
+	return sb.String()
+}
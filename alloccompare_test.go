@@ -0,0 +1,52 @@
+package heapescapeanalysis
+
+import "testing"
+
+func TestCompareAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds its own allocations")
+	}
+	var sink *int
+	var pair [2]*int
+	got := CompareAllocs("newOf", map[string]func(){
+		"two":  func() { pair = [2]*int{newOf[int](), newOf[int]()} },
+		"none": func() {},
+		"one":  func() { sink = newOf[int]() },
+	})
+	result = sink
+	result = pair
+
+	want := "newOf: allocations per run\n" +
+		"VARIANT  ALLOCS/RUN  VS FEWEST\n" +
+		"none     0           +0\n" +
+		"one      1           +1\n" +
+		"two      2           +2\n"
+	if got != want {
+		t.Errorf("CompareAllocs =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCompareAllocsRatio(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds its own allocations")
+	}
+	var sink *int
+	var triple [3]*int
+	got := CompareAllocs("ratio", map[string]func(){
+		"one":   func() { sink = newOf[int]() },
+		"three": func() { triple = [3]*int{newOf[int](), newOf[int](), newOf[int]()} },
+	})
+	result = sink
+	result = triple
+
+	want := "ratio: allocations per run\n" +
+		"VARIANT  ALLOCS/RUN  VS FEWEST\n" +
+		"one      1           +0\n" +
+		"three    3           +2 (3.00x)\n"
+	if got != want {
+		t.Errorf("CompareAllocs =\n%s\nwant\n%s", got, want)
+	}
+	if CompareAllocs("empty", nil) != "empty: allocations per run\nVARIANT  ALLOCS/RUN  VS FEWEST\n" {
+		t.Error("CompareAllocs with no variants did not return just the header")
+	}
+}
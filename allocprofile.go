@@ -0,0 +1,123 @@
+package heapescapeanalysis
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+)
+
+// sizeClasses lists the runtime's small-object size classes in bytes
+// (runtime/sizeclasses.go). Larger objects are allocated in whole pages.
+var sizeClasses = [...]uintptr{
+	8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224,
+	240, 256, 288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768,
+	896, 1024, 1152, 1280, 1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200,
+	3456, 4096, 4864, 5376, 6144, 6528, 6784, 6912, 8192, 9472, 9728, 10240,
+	10880, 12288, 13568, 14336, 16384, 18432, 19072, 20480, 21760, 24576,
+	27264, 28672, 32768,
+}
+
+const pageSize = 8192
+
+// sizeClassFor rounds size up to the size class the allocator would use.
+func sizeClassFor(size uintptr) uintptr {
+	i := sort.Search(len(sizeClasses), func(i int) bool { return sizeClasses[i] >= size })
+	if i < len(sizeClasses) {
+		return sizeClasses[i]
+	}
+	return (size + pageSize - 1) / pageSize * pageSize
+}
+
+type allocCounts struct {
+	objects int64
+	bytes   int64
+}
+
+// stackContains reports whether the call stack includes function fn.
+func stackContains(stack [32]uintptr, fn string) bool {
+	n := 0
+	for n < len(stack) && stack[n] != 0 {
+		n++
+	}
+	frames := runtime.CallersFrames(stack[:n])
+	for {
+		frame, more := frames.Next()
+		if frame.Function == fn {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// memProfileSnapshot returns cumulative allocation counts per call stack.
+func memProfileSnapshot() map[[32]uintptr]allocCounts {
+	var records []runtime.MemProfileRecord
+	n, _ := runtime.MemProfile(nil, true)
+	for {
+		records = make([]runtime.MemProfileRecord, n+50)
+		var ok bool
+		n, ok = runtime.MemProfile(records, true)
+		if ok {
+			records = records[:n]
+			break
+		}
+	}
+
+	snap := make(map[[32]uintptr]allocCounts, len(records))
+	for _, r := range records {
+		snap[r.Stack0] = allocCounts{r.AllocObjects, r.AllocBytes}
+	}
+	return snap
+}
+
+// AllocSizeHistogram calls f runs times and returns how many heap
+// allocations were made in each size class, keyed by the class size in
+// bytes. It shows whether a function makes one large allocation or many
+// small ones, which allocation counts and byte totals alone do not.
+//
+// It works by setting runtime.MemProfileRate to 1 while f runs, so that
+// every allocation is recorded, and diffing the memory profile before and
+// after. Caveats:
+//   - Only allocations whose recorded call stack includes f are counted.
+//     The profiler keeps 32 frames, so allocations nested deeper than that
+//     below f are missed.
+//   - Profile data is only published at the end of a GC cycle; the function
+//     forces collections, which makes it unsuitable for hot paths.
+//   - Pointer-free objects under 16 bytes are packed together by the tiny
+//     allocator and are reported as the 16-byte blocks holding them.
+//   - The profile aggregates by call stack. A single call site that
+//     allocates several sizes (for example append in a loop) is reported
+//     at its average size.
+func AllocSizeHistogram(runs int, f func()) map[uintptr]int {
+	oldRate := runtime.MemProfileRate
+	runtime.MemProfileRate = 1
+	defer func() { runtime.MemProfileRate = oldRate }()
+
+	// Two cycles publish everything allocated so far
+	runtime.GC()
+	runtime.GC()
+	before := memProfileSnapshot()
+
+	for i := 0; i < runs; i++ {
+		f()
+	}
+
+	runtime.GC()
+	runtime.GC()
+	after := memProfileSnapshot()
+
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	hist := make(map[uintptr]int)
+	for stack, a := range after {
+		b := before[stack]
+		objects := a.objects - b.objects
+		if objects <= 0 || !stackContains(stack, fn) {
+			continue
+		}
+		size := uintptr((a.bytes - b.bytes) / objects)
+		hist[sizeClassFor(size)] += int(objects)
+	}
+	return hist
+}
@@ -0,0 +1,56 @@
+package heapescapeanalysis
+
+import "testing"
+
+var histogramSink []interface{}
+
+func TestSizeClassFor(t *testing.T) {
+	tests := []struct {
+		size, want uintptr
+	}{
+		{1, 8}, {8, 8}, {9, 16}, {24, 24}, {25, 32}, {1000, 1024},
+		{32768, 32768}, {32769, 40960}, {100000, 106496},
+	}
+	for _, tt := range tests {
+		if got := sizeClassFor(tt.size); got != tt.want {
+			t.Errorf("sizeClassFor(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestAllocSizeHistogram(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds its own allocations")
+	}
+	const runs = 50
+
+	t.Run("one large", func(t *testing.T) {
+		hist := AllocSizeHistogram(runs, func() {
+			histogramSink = append(histogramSink[:0], make([]byte, 1000))
+		})
+		if hist[1024] < runs {
+			t.Errorf("1024-byte class count = %d, want >= %d (histogram %v)", hist[1024], runs, hist)
+		}
+	})
+
+	t.Run("many small", func(t *testing.T) {
+		hist := AllocSizeHistogram(runs, func() {
+			histogramSink = histogramSink[:0]
+			for i := 0; i < 4; i++ {
+				histogramSink = append(histogramSink, newWidget(i))
+			}
+		})
+		if hist[16] < 4*runs {
+			t.Errorf("16-byte class count = %d, want >= %d (histogram %v)", hist[16], 4*runs, hist)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		hist := AllocSizeHistogram(runs, func() {
+			result = returnValue()
+		})
+		if len(hist) != 0 {
+			t.Errorf("non-allocating function histogram = %v, want empty", hist)
+		}
+	})
+}
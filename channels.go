@@ -0,0 +1,131 @@
+package heapescapeanalysis
+
+// Channel element types and escape
+//
+// A channel's element type decides what crosses it. A chan *int carries
+// addresses, and the compiler cannot tell who will receive them or for how
+// long, so every local whose address is sent is moved to the heap. A chan
+// int copies the values into the channel's buffer, leaving the locals on the
+// stack. Either way the channel itself is heap allocated once it is returned.
+
+const bufferedChannelSize = 4
+
+//go:noinline
+func returnBufferedChannel() chan *int {
+	ch := make(chan *int, bufferedChannelSize)
+	for i := 0; i < bufferedChannelSize; i++ {
+		v := i * i // Moved to heap: its address is sent
+		ch <- &v
+	}
+	close(ch)
+	return ch
+}
+
+//go:noinline
+func returnValueChannel() chan int {
+	ch := make(chan int, bufferedChannelSize)
+	for i := 0; i < bufferedChannelSize; i++ {
+		v := i * i // Copied into the channel buffer
+		ch <- v
+	}
+	close(ch)
+	return ch
+}
+
+// Channels of large structs versus channels of pointers
+//
+// Sending a LargeStruct by value copies all 24,000 bytes into the channel's
+// buffer and copies them out again on receive, but nothing is allocated per
+// message. Sending *LargeStruct moves only a pointer, yet the struct it
+// points to must be on the heap because the compiler cannot know who will
+// receive it, so every message is an allocation for the GC to reclaim.
+// Allocating means zeroing a fresh 24 KB object, which can cost more than
+// the two copies; pointers pay off when the structs are recycled (a pool or
+// free list) so the allocation is made once rather than per message.
+
+//go:noinline
+func channelOfStructs(ch chan LargeStruct, n int) int {
+	var s LargeStruct // Stays on the stack
+	s.data[0] = n
+	ch <- s // Copied into the buffer
+	got := <-ch
+	return got.data[0]
+}
+
+//go:noinline
+func channelOfPointers(ch chan *LargeStruct, n int) int {
+	s := &LargeStruct{} // Escapes: sent through the channel
+	s.data[0] = n
+	ch <- s // Only the pointer is copied
+	return (<-ch).data[0]
+}
+
+// Sending interface{} values through a channel
+//
+// A chan interface{} carries interface values, so every send of an int
+// first boxes it. The box has to be on the heap because the value now lives
+// in the channel's buffer, where the receiving goroutine can pick it up long
+// after the sender returns. Only ints below 256 come from the runtime's
+// static table; everything else costs one allocation per send, on top of the
+// type assertion at the receiver. A chan int copies the 8-byte value into
+// the buffer and allocates nothing.
+
+//go:noinline
+func interfaceThroughChannel(ch chan interface{}, n int) int {
+	for i := 0; i < n; i++ {
+		ch <- 1000 + i // Boxed on every send
+	}
+	sum := 0
+	for i := 0; i < n; i++ {
+		sum += (<-ch).(int)
+	}
+	return sum
+}
+
+//go:noinline
+func intThroughChannel(ch chan int, n int) int {
+	for i := 0; i < n; i++ {
+		ch <- 1000 + i // Copied into the buffer
+	}
+	sum := 0
+	for i := 0; i < n; i++ {
+		sum += <-ch
+	}
+	return sum
+}
+
+// Capturing a channel in a goroutine closure versus passing it in
+//
+// A closure started with go outlives the function that starts it, so the
+// closure escapes, and every variable it captures goes with it: ch and n are
+// copied into the closure's heap-allocated context. The channel itself was
+// on the heap already, since make(chan) always allocates. Passing ch and n
+// as arguments looks cheaper, but since Go 1.17 the compiler rewrites
+// go f(ch, n) into go func() { f(ch, n) }() with the arguments evaluated
+// first, so it allocates the same wrapper closure: both versions cost the
+// channel plus one closure per call. What the parameter does buy is
+// clarity. The goroutine receives the values as they were at the go
+// statement, whereas a captured variable that is reassigned later is shared
+// by reference, moved to the heap in its own right, and racy if the
+// goroutine reads it while the caller writes it.
+
+//go:noinline
+func closureCapturesChannel(n int) int {
+	ch := make(chan int) // Always heap allocated
+	go func() {
+		ch <- n * 2 // ch and n are copied into the escaping closure
+	}()
+	return <-ch
+}
+
+//go:noinline
+func sendDouble(ch chan<- int, n int) {
+	ch <- n * 2
+}
+
+//go:noinline
+func channelAsGoroutineParam(n int) int {
+	ch := make(chan int)
+	go sendDouble(ch, n) // Wrapped in a closure holding ch and n
+	return <-ch
+}
@@ -0,0 +1,194 @@
+package heapescapeanalysis
+
+import "testing"
+
+func BenchmarkComparison_ChannelElementType(b *testing.B) {
+	b.Run("Pointer-Channel", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for p := range returnBufferedChannel() {
+				r += *p
+			}
+		}
+		result = r
+	})
+
+	b.Run("Value-Channel", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for v := range returnValueChannel() {
+				r += v
+			}
+		}
+		result = r
+	})
+}
+
+func TestChannelElementType(t *testing.T) {
+	var fromPointers, fromValues []int
+	for p := range returnBufferedChannel() {
+		fromPointers = append(fromPointers, *p)
+	}
+	for v := range returnValueChannel() {
+		fromValues = append(fromValues, v)
+	}
+	if len(fromPointers) != bufferedChannelSize || len(fromValues) != bufferedChannelSize {
+		t.Fatalf("received %d pointers and %d values, want %d each", len(fromPointers), len(fromValues), bufferedChannelSize)
+	}
+	for i := range fromValues {
+		if fromPointers[i] != fromValues[i] {
+			t.Errorf("element %d: pointer channel = %d, value channel = %d", i, fromPointers[i], fromValues[i])
+		}
+	}
+}
+
+func TestValueChannelAllocs(t *testing.T) {
+	var r int
+	allocs := testing.AllocsPerRun(100, func() {
+		for v := range returnValueChannel() {
+			r += v
+		}
+	})
+	result = r
+	// Only the channel itself is allocated, not its elements
+	if allocs != 1 {
+		t.Errorf("returnValueChannel allocs = %v, want 1", allocs)
+	}
+}
+
+func BenchmarkComparison_ChannelLargeElements(b *testing.B) {
+	b.Run("Struct-Values", func(b *testing.B) {
+		ch := make(chan LargeStruct, 1)
+		var r int
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r = channelOfStructs(ch, i)
+		}
+		result = r
+	})
+
+	b.Run("Struct-Pointers", func(b *testing.B) {
+		ch := make(chan *LargeStruct, 1)
+		var r int
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r = channelOfPointers(ch, i)
+		}
+		result = r
+	})
+}
+
+func TestChannelLargeElements(t *testing.T) {
+	values, pointers := make(chan LargeStruct, 1), make(chan *LargeStruct, 1)
+	for _, n := range []int{0, 1, 12345} {
+		if got := channelOfStructs(values, n); got != n {
+			t.Errorf("channelOfStructs(%d) = %d", n, got)
+		}
+		if got := channelOfPointers(pointers, n); got != n {
+			t.Errorf("channelOfPointers(%d) = %d", n, got)
+		}
+	}
+
+	var r int
+	if allocs := testing.AllocsPerRun(100, func() { r = channelOfStructs(values, 1) }); allocs != 0 {
+		t.Errorf("channelOfStructs allocs = %v, want 0", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { r = channelOfPointers(pointers, 1) }); allocs != 1 {
+		t.Errorf("channelOfPointers allocs = %v, want 1", allocs)
+	}
+	result = r
+}
+
+func BenchmarkComparison_InterfaceChannel(b *testing.B) {
+	const n = 64
+
+	b.Run("Interface-Channel", func(b *testing.B) {
+		ch := make(chan interface{}, n)
+		var r int
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r = interfaceThroughChannel(ch, n)
+		}
+		result = r
+	})
+
+	b.Run("Int-Channel", func(b *testing.B) {
+		ch := make(chan int, n)
+		var r int
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r = intThroughChannel(ch, n)
+		}
+		result = r
+	})
+}
+
+func TestInterfaceChannel(t *testing.T) {
+	const n = 16
+	boxed, typed := make(chan interface{}, n), make(chan int, n)
+	want := n*1000 + n*(n-1)/2
+	if got := interfaceThroughChannel(boxed, n); got != want {
+		t.Errorf("interfaceThroughChannel = %d, want %d", got, want)
+	}
+	if got := intThroughChannel(typed, n); got != want {
+		t.Errorf("intThroughChannel = %d, want %d", got, want)
+	}
+
+	var r int
+	typedAllocs := testing.AllocsPerRun(100, func() { r = intThroughChannel(typed, n) })
+	boxedAllocs := testing.AllocsPerRun(100, func() { r = interfaceThroughChannel(boxed, n) })
+	result = r
+	if typedAllocs != 0 {
+		t.Errorf("intThroughChannel allocs = %v, want 0", typedAllocs)
+	}
+	if boxedAllocs != n {
+		t.Errorf("interfaceThroughChannel allocs = %v, want %d (one box per send)", boxedAllocs, n)
+	}
+}
+
+func BenchmarkComparison_GoroutineChannelCapture(b *testing.B) {
+	b.Run("Captured", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = closureCapturesChannel(i)
+		}
+		result = r
+	})
+
+	b.Run("Parameter", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = channelAsGoroutineParam(i)
+		}
+		result = r
+	})
+}
+
+func TestGoroutineChannelCapture(t *testing.T) {
+	if got := closureCapturesChannel(21); got != 42 {
+		t.Errorf("closureCapturesChannel(21) = %d, want 42", got)
+	}
+	if got := channelAsGoroutineParam(21); got != 42 {
+		t.Errorf("channelAsGoroutineParam(21) = %d, want 42", got)
+	}
+
+	var r int
+	n := 0
+	paramAllocs := testing.AllocsPerRun(100, func() { n++; r = channelAsGoroutineParam(n) })
+	captureAllocs := testing.AllocsPerRun(100, func() { n++; r = closureCapturesChannel(n) })
+	result = r
+	if paramAllocs != 2 {
+		t.Errorf("channelAsGoroutineParam allocs = %v, want 2 (the channel and the go wrapper)", paramAllocs)
+	}
+	if captureAllocs != paramAllocs {
+		t.Errorf("closureCapturesChannel allocs = %v, channelAsGoroutineParam = %v, want equal", captureAllocs, paramAllocs)
+	}
+}
@@ -0,0 +1,430 @@
+package heapescapeanalysis
+
+import "net/http"
+
+// Capturing the receiver in event callbacks
+//
+// A callback that mentions s.field captures s itself, not the field. Once
+// that callback is stored somewhere long-lived (an event bus, a timer, a
+// global registry) the whole receiver, and everything it points to, stays
+// reachable for as long as the callback does. Copying the fields a callback
+// needs into locals first means only those values are captured.
+
+// Server holds state that callbacks commonly need a small part of.
+type Server struct {
+	name  string
+	state [4096]byte // Large state that callbacks should not pin
+}
+
+// eventBus stores callbacks for the lifetime of the process.
+type eventBus struct {
+	handlers []func() string
+}
+
+// registerCapturingReceiver stores a callback that captures s, so the bus
+// retains the entire Server.
+//
+//go:noinline
+func (s *Server) registerCapturingReceiver(bus *eventBus) {
+	bus.handlers = append(bus.handlers, func() string {
+		return s.name // Captures s, keeping all of it alive
+	})
+}
+
+// registerCapturingFields stores a callback that captures only the name.
+//
+//go:noinline
+func (s *Server) registerCapturingFields(bus *eventBus) {
+	name := s.name // Copy what the callback needs
+	bus.handlers = append(bus.handlers, func() string {
+		return name // Server can be collected independently
+	})
+}
+
+// Deferring closures inside loops
+//
+// A defer inside a loop cannot be open-coded, so each iteration pushes a
+// deferred call record that lives until the function returns, and the
+// closure it defers must be heap allocated to survive that long. With n
+// iterations that is n closures and n pending calls, all released only at
+// the end. Moving the loop body into its own function lets each defer be
+// open-coded and run at the end of its iteration, with the closure on the
+// stack.
+
+//go:noinline
+func deferInLoop(n int) (total int) {
+	for i := 0; i < n; i++ {
+		defer func() {
+			total += i // Captures total and i, runs only when deferInLoop returns
+		}()
+	}
+	return 0
+}
+
+//go:noinline
+func deferExtracted(n int) (total int) {
+	for i := 0; i < n; i++ {
+		total += deferredStep(i)
+	}
+	return total
+}
+
+// deferredStep is the loop body of deferInLoop as its own function, so its
+// defer runs at the end of every iteration.
+//
+//go:noinline
+func deferredStep(i int) (r int) {
+	defer func() {
+		r += i // Open-coded defer, closure stays on the stack
+	}()
+	return 0
+}
+
+// Capturing a made slice in a closure
+//
+// make([]int, 64) with a constant size normally stays on the stack as long
+// as the slice does not escape. Once a closure that reads it is handed to
+// code that may keep the closure (here runTask stores it), the closure is
+// heap allocated and the slice it captures must outlive the frame too, so
+// the backing array moves to the heap as well: two allocations where there
+// were none. Passing the slice as an argument to a function that does not
+// retain it keeps both on the stack.
+
+// lastTask keeps the most recent task, like a scheduler's queue would.
+var lastTask func() int
+
+//go:noinline
+func runTask(task func() int) int {
+	lastTask = task // task escapes
+	return task()
+}
+
+//go:noinline
+func sumInts(xs []int) int {
+	sum := 0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum
+}
+
+//go:noinline
+func closureOverMadeSlice() int {
+	buf := make([]int, 64) // Moved to heap because the closure escapes
+	for i := range buf {
+		buf[i] = i
+	}
+	return runTask(func() int {
+		return sumInts(buf) // Captures buf
+	})
+}
+
+//go:noinline
+func madeSliceAsParam() int {
+	buf := make([]int, 64) // Stays on the stack
+	for i := range buf {
+		buf[i] = i
+	}
+	return sumInts(buf) // Passed, not captured
+}
+
+// Maps of capturing closures versus a switch
+//
+// A router that stores one closure per route allocates the map, its
+// buckets, and every closure that captures something; the captured state
+// stays reachable for as long as the map does. When the set of routes is
+// fixed at compile time, a switch over string constants dispatches directly
+// and needs no allocation at all.
+
+var handlerRoutes = [...]string{
+	"/users",
+	"/orders",
+	"/items",
+	"/carts",
+	"/payments",
+	"/invoices",
+	"/reports",
+	"/search",
+	"/login",
+	"/logout",
+	"/signup",
+	"/profile",
+	"/settings",
+	"/health",
+	"/metrics",
+	"/status",
+	"/admin",
+	"/files",
+	"/uploads",
+	"/webhooks",
+}
+
+// handlerMap returns one closure per route, each adding its weight to hits.
+//
+//go:noinline
+func handlerMap(hits *int) map[string]func() {
+	m := make(map[string]func(), len(handlerRoutes))
+	for i, route := range handlerRoutes {
+		m[route] = func() {
+			*hits += i + 1 // Captures hits and i: one allocation per route
+		}
+	}
+	return m
+}
+
+// dispatchRoute runs the handler for route and reports whether it exists.
+//
+//go:noinline
+func dispatchRoute(route string, hits *int) bool {
+	switch route {
+	case "/users":
+		*hits += 1
+	case "/orders":
+		*hits += 2
+	case "/items":
+		*hits += 3
+	case "/carts":
+		*hits += 4
+	case "/payments":
+		*hits += 5
+	case "/invoices":
+		*hits += 6
+	case "/reports":
+		*hits += 7
+	case "/search":
+		*hits += 8
+	case "/login":
+		*hits += 9
+	case "/logout":
+		*hits += 10
+	case "/signup":
+		*hits += 11
+	case "/profile":
+		*hits += 12
+	case "/settings":
+		*hits += 13
+	case "/health":
+		*hits += 14
+	case "/metrics":
+		*hits += 15
+	case "/status":
+		*hits += 16
+	case "/admin":
+		*hits += 17
+	case "/files":
+		*hits += 18
+	case "/uploads":
+		*hits += 19
+	case "/webhooks":
+		*hits += 20
+	default:
+		return false
+	}
+	return true
+}
+
+// Closures sharing one captured variable
+//
+// When two closures capture the same variable they do not get copies: the
+// compiler moves the variable into a single heap cell and both closures hold
+// a pointer to it, so an increment through one is visible through the other.
+// Returning the closures means the cell and both closure objects outlive the
+// call, costing three allocations per counter. A struct with pointer-receiver
+// methods expresses the same shared state, and when the caller keeps the
+// struct local it stays on the stack.
+
+//go:noinline
+func makeCounter() (inc func(), get func() int) {
+	n := 0 // Moved to heap: shared by both closures
+	inc = func() { n++ }
+	get = func() int { return n }
+	return inc, get
+}
+
+// counter is the struct equivalent of makeCounter.
+type counter struct {
+	n int
+}
+
+func (c *counter) inc() { c.n++ }
+
+func (c *counter) get() int { return c.n }
+
+//go:noinline
+func countWithClosures(times int) int {
+	inc, get := makeCounter()
+	for i := 0; i < times; i++ {
+		inc()
+	}
+	return get()
+}
+
+//go:noinline
+func countWithStruct(times int) int {
+	var c counter // Stays on stack: only &c is passed to inlined methods
+	for i := 0; i < times; i++ {
+		c.inc()
+	}
+	return c.get()
+}
+
+// Closures returned from methods
+//
+// A method that returns a closure over a field, like Calculator.Adder,
+// captures the receiver pointer, not the field's current value. The closure
+// escapes to the caller, so the receiver escapes with it: a Calculator the
+// caller declared locally is moved to the heap, and it stays reachable for
+// as long as anyone holds the adder, together with everything it points to.
+// Builder-style APIs that hand out bound closures pay this on every call.
+// (When Adder is inlined and the adder is only called locally, both can stay
+// on the stack; the cost appears once the adder is returned or stored.) A
+// plain function that takes the base as a parameter captures nothing and
+// allocates nothing.
+
+// Calculator holds a base value that its adders add to.
+type Calculator struct {
+	base int
+}
+
+// Adder returns a function that adds the calculator's base to its argument.
+// Later changes to base are seen by adders returned earlier.
+func (c *Calculator) Adder() func(int) int {
+	return func(x int) int {
+		return c.base + x // Captures c: the receiver escapes
+	}
+}
+
+//go:noinline
+func boundAdder(base int) func(int) int {
+	c := Calculator{base: base} // Moved to heap: captured by the adder
+	return c.Adder()
+}
+
+//go:noinline
+func addBase(base, x int) int {
+	return base + x
+}
+
+// Appending to a slice captured by a closure
+//
+// An accumulator closure that appends to a captured slice mutates the slice
+// variable, so it captures it by reference. As long as the compiler can see
+// every use of the closure, both can stay on the stack. Once the closure is
+// handed to code it cannot analyze (an interface method, a func value, a
+// registry), the closure escapes, and because it must be able to update out
+// after this function has returned, the out variable itself is moved to the
+// heap too: one allocation for the slice header's cell, one for the closure,
+// plus the backing array. Passing the slice in and returning the grown one,
+// as append itself does, keeps the header in a local variable and leaves
+// only the backing array to allocate.
+
+// forEachHook calls fn with 0..n-1. It is a variable so that the compiler,
+// like with a plugin or interface callback, cannot see what it does with fn.
+var forEachHook = func(n int, fn func(int)) {
+	for i := 0; i < n; i++ {
+		fn(i)
+	}
+}
+
+//go:noinline
+func appendInClosure(n int) []int {
+	out := make([]int, 0, n) // Moved to heap: captured and updated by the closure
+	forEachHook(n, func(x int) {
+		out = append(out, x) // The closure escapes through forEachHook
+	})
+	return out
+}
+
+//go:noinline
+func appendValue(out []int, x int) []int {
+	return append(out, x)
+}
+
+//go:noinline
+func appendViaParam(n int) []int {
+	out := make([]int, 0, n) // Only the backing array escapes
+	for i := 0; i < n; i++ {
+		out = appendValue(out, i)
+	}
+	return out
+}
+
+// Composing HTTP middleware from closures
+//
+// The usual middleware signature, func(http.Handler) http.Handler, wraps the
+// next handler in a closure that captures it, so composing an n-layer chain
+// allocates n closures, plus whatever each layer captures besides next. Done
+// once at startup that is harmless; done per request, as routers that build
+// a chain per route match or per tenant sometimes do, it is n allocations on
+// every request before any handler has run. A precompiled chain keeps the
+// steps in a slice and runs them in a loop, so building it only stores the
+// slice and the final handler. The price is a less general step signature:
+// a step can stop the request, but it cannot wrap the rest of the chain
+// (for example to time it or to recover panics).
+
+//go:noinline
+func buildMiddlewareChain(mws []func(http.Handler) http.Handler, final http.Handler) http.Handler {
+	h := final
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h) // Each layer allocates a closure capturing h
+	}
+	return h
+}
+
+// middlewareStep runs before the final handler and reports whether the
+// request should continue.
+type middlewareStep func(w http.ResponseWriter, r *http.Request) bool
+
+// compiledChain runs its steps in order and then the final handler.
+type compiledChain struct {
+	steps []middlewareStep
+	final http.Handler
+}
+
+func (c *compiledChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, step := range c.steps {
+		if !step(w, r) {
+			return
+		}
+	}
+	c.final.ServeHTTP(w, r)
+}
+
+//go:noinline
+func compileMiddleware(steps []middlewareStep, final http.Handler) compiledChain {
+	return compiledChain{steps: steps, final: final} // No per-layer allocation
+}
+
+// Package-level functions versus capturing closures as func values
+//
+// A func value is a pointer to a small object holding the code pointer and
+// any captured variables. A package-level function captures nothing, so the
+// compiler emits that object once in read-only data and passing
+// addToHookTotal anywhere, even to code that keeps it, costs nothing. A
+// function literal that captures nothing is compiled the same way. As soon
+// as the literal captures a variable, each evaluation builds a new object
+// holding the captured values, and when the func value escapes (here into
+// forEachHook, whose behaviour the compiler cannot see) that object is
+// allocated on the heap on every call. Method values such as s.Method are
+// closures too: they capture the receiver. To keep a hot callback
+// allocation-free, pass a top-level function and hand it its state through
+// parameters or through state it can reach on its own.
+
+// hookTotal accumulates what the callbacks passed to forEachHook see.
+var hookTotal int
+
+func addToHookTotal(x int) {
+	hookTotal += x
+}
+
+//go:noinline
+func passPackageFunc(n int) {
+	forEachHook(n, addToHookTotal) // Static func value, no allocation
+}
+
+//go:noinline
+func passClosure(n, offset int) {
+	forEachHook(n, func(x int) {
+		hookTotal += x + offset // Captures offset: a new closure per call
+	})
+}
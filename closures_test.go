@@ -0,0 +1,490 @@
+package heapescapeanalysis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+func BenchmarkComparison_CallbackCapture(b *testing.B) {
+	b.Run("Capture-Receiver", func(b *testing.B) {
+		s := &Server{name: "api"}
+		bus := &eventBus{handlers: make([]func() string, 0, 1)}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bus.handlers = bus.handlers[:0]
+			s.registerCapturingReceiver(bus)
+		}
+		result = bus
+	})
+
+	b.Run("Capture-Fields", func(b *testing.B) {
+		s := &Server{name: "api"}
+		bus := &eventBus{handlers: make([]func() string, 0, 1)}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bus.handlers = bus.handlers[:0]
+			s.registerCapturingFields(bus)
+		}
+		result = bus
+	})
+}
+
+// serverCollected reports whether a Server registered on bus via register
+// is garbage collected while bus is still alive.
+func serverCollected(register func(*Server, *eventBus)) bool {
+	bus := &eventBus{}
+	collected := make(chan struct{})
+	func() {
+		s := &Server{name: "api"}
+		runtime.SetFinalizer(s, func(*Server) { close(collected) })
+		register(s, bus)
+	}()
+
+	defer runtime.KeepAlive(bus)
+	for i := 0; i < 5; i++ {
+		runtime.GC()
+		select {
+		case <-collected:
+			return true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return false
+}
+
+func TestCallbackCaptureRetention(t *testing.T) {
+	if !serverCollected((*Server).registerCapturingFields) {
+		t.Error("Server registered with registerCapturingFields was retained by the bus")
+	}
+	if serverCollected((*Server).registerCapturingReceiver) {
+		t.Error("Server registered with registerCapturingReceiver was collected while the bus held its callback")
+	}
+}
+
+func TestCallbackCaptureResults(t *testing.T) {
+	s := &Server{name: "api"}
+	bus := &eventBus{}
+	s.registerCapturingReceiver(bus)
+	s.registerCapturingFields(bus)
+	for i, h := range bus.handlers {
+		if got := h(); got != "api" {
+			t.Errorf("handler %d = %q, want %q", i, got, "api")
+		}
+	}
+}
+
+func BenchmarkComparison_DeferInLoop(b *testing.B) {
+	b.Run("Defer-In-Loop", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = deferInLoop(100)
+		}
+		result = r
+	})
+
+	b.Run("Extracted-Function", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = deferExtracted(100)
+		}
+		result = r
+	})
+}
+
+func TestDeferInLoop(t *testing.T) {
+	const want = 99 * 100 / 2
+	if got := deferInLoop(100); got != want {
+		t.Errorf("deferInLoop(100) = %d, want %d", got, want)
+	}
+	if got := deferExtracted(100); got != want {
+		t.Errorf("deferExtracted(100) = %d, want %d", got, want)
+	}
+}
+
+func TestDeferExtractedAllocs(t *testing.T) {
+	var r int
+	allocs := testing.AllocsPerRun(100, func() {
+		r = deferExtracted(100)
+	})
+	result = r
+	if allocs != 0 {
+		t.Errorf("deferExtracted allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_ClosureOverMadeSlice(b *testing.B) {
+	b.Run("Captured", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = closureOverMadeSlice()
+		}
+		result = r
+	})
+
+	b.Run("Parameter", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = madeSliceAsParam()
+		}
+		result = r
+	})
+}
+
+func TestClosureOverMadeSlice(t *testing.T) {
+	const want = 63 * 64 / 2
+	if got := closureOverMadeSlice(); got != want {
+		t.Errorf("closureOverMadeSlice() = %d, want %d", got, want)
+	}
+	if got := madeSliceAsParam(); got != want {
+		t.Errorf("madeSliceAsParam() = %d, want %d", got, want)
+	}
+
+	var r int
+	captured := testing.AllocsPerRun(100, func() { r = closureOverMadeSlice() })
+	param := testing.AllocsPerRun(100, func() { r = madeSliceAsParam() })
+	result = r
+	if captured < 2 {
+		t.Errorf("closureOverMadeSlice allocs = %v, want closure and backing array on the heap", captured)
+	}
+	if param != 0 {
+		t.Errorf("madeSliceAsParam allocs = %v, want 0", param)
+	}
+}
+
+func BenchmarkComparison_HandlerDispatch(b *testing.B) {
+	b.Run("Closure-Map", func(b *testing.B) {
+		var hits int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m := handlerMap(&hits)
+			for _, route := range handlerRoutes {
+				m[route]()
+			}
+		}
+		result = hits
+	})
+
+	b.Run("Switch", func(b *testing.B) {
+		var hits int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, route := range handlerRoutes {
+				dispatchRoute(route, &hits)
+			}
+		}
+		result = hits
+	})
+}
+
+func TestHandlerDispatch(t *testing.T) {
+	var fromMap, fromSwitch int
+	m := handlerMap(&fromMap)
+	if len(m) != len(handlerRoutes) {
+		t.Fatalf("handlerMap has %d routes, want %d", len(m), len(handlerRoutes))
+	}
+	for _, route := range handlerRoutes {
+		m[route]()
+		if !dispatchRoute(route, &fromSwitch) {
+			t.Errorf("dispatchRoute(%q) = false, want true", route)
+		}
+		if fromMap != fromSwitch {
+			t.Fatalf("after %s: map hits = %d, switch hits = %d", route, fromMap, fromSwitch)
+		}
+	}
+	if dispatchRoute("/missing", &fromSwitch) {
+		t.Error("dispatchRoute(/missing) = true, want false")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, route := range handlerRoutes {
+			dispatchRoute(route, &fromSwitch)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("dispatchRoute allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_SharedCounter(b *testing.B) {
+	b.Run("Closures", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = countWithClosures(10)
+		}
+		result = r
+	})
+
+	b.Run("Struct", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = countWithStruct(10)
+		}
+		result = r
+	})
+}
+
+func TestMakeCounterSharesState(t *testing.T) {
+	inc, get := makeCounter()
+	inc()
+	inc()
+	if got := get(); got != 2 {
+		t.Errorf("get() after two inc() = %d, want 2", got)
+	}
+
+	inc2, get2 := makeCounter()
+	inc2()
+	if got := get2(); got != 1 {
+		t.Errorf("second counter get() = %d, want 1", got)
+	}
+	if got := get(); got != 2 {
+		t.Errorf("first counter changed to %d by the second, want 2", got)
+	}
+
+	if got, want := countWithClosures(10), countWithStruct(10); got != want {
+		t.Errorf("countWithClosures(10) = %d, countWithStruct(10) = %d", got, want)
+	}
+	var r int
+	allocs := testing.AllocsPerRun(100, func() { r = countWithStruct(10) })
+	result = r
+	if allocs != 0 {
+		t.Errorf("countWithStruct allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_MethodClosure(b *testing.B) {
+	b.Run("Method-Closure", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = boundAdder(i)(1)
+		}
+		result = r
+	})
+
+	b.Run("Free-Function", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = addBase(i, 1)
+		}
+		result = r
+	})
+}
+
+func TestCalculatorAdder(t *testing.T) {
+	c := &Calculator{base: 10}
+	add := c.Adder()
+	if got := add(5); got != 15 {
+		t.Errorf("add(5) = %d, want 15", got)
+	}
+	c.base = 20
+	if got := add(5); got != 25 {
+		t.Errorf("add(5) after base = 20: got %d, want 25 (the adder shares the receiver)", got)
+	}
+	if got, want := boundAdder(3)(4), addBase(3, 4); got != want {
+		t.Errorf("boundAdder(3)(4) = %d, addBase(3, 4) = %d", got, want)
+	}
+
+	var r int
+	base := 3
+	free := testing.AllocsPerRun(100, func() { base++; r = addBase(base, 4) })
+	bound := testing.AllocsPerRun(100, func() { base++; r = boundAdder(base)(4) })
+	result = r
+	if free != 0 {
+		t.Errorf("addBase allocs = %v, want 0", free)
+	}
+	if bound < 2 {
+		t.Errorf("boundAdder allocs = %v, want the Calculator and the closure on the heap", bound)
+	}
+}
+
+func BenchmarkComparison_AppendInClosure(b *testing.B) {
+	b.Run("Captured-Slice", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = appendInClosure(100)
+		}
+		result = r
+	})
+
+	b.Run("Slice-Parameter", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = appendViaParam(100)
+		}
+		result = r
+	})
+}
+
+func TestAppendInClosure(t *testing.T) {
+	captured, param := appendInClosure(100), appendViaParam(100)
+	if len(captured) != 100 || !slices.Equal(captured, param) {
+		t.Errorf("appendInClosure and appendViaParam differ (len %d and %d)", len(captured), len(param))
+	}
+
+	var r []int
+	paramAllocs := testing.AllocsPerRun(100, func() { r = appendViaParam(100) })
+	capturedAllocs := testing.AllocsPerRun(100, func() { r = appendInClosure(100) })
+	result = r
+	if paramAllocs != 1 {
+		t.Errorf("appendViaParam allocs = %v, want 1 (the backing array)", paramAllocs)
+	}
+	if capturedAllocs != 3 {
+		t.Errorf("appendInClosure allocs = %v, want 3 (backing array, slice cell and closure)", capturedAllocs)
+	}
+}
+
+// layerMiddleware returns a middleware that adds name to the X-Layer header.
+func layerMiddleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Layer", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// layerStep is the compiledChain equivalent of layerMiddleware.
+func layerStep(name string) middlewareStep {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Add("X-Layer", name)
+		return true
+	}
+}
+
+var layerNames = []string{"log", "auth", "gzip", "cors", "trace"}
+
+func layeredChains() ([]func(http.Handler) http.Handler, []middlewareStep) {
+	var mws []func(http.Handler) http.Handler
+	var steps []middlewareStep
+	for _, name := range layerNames {
+		mws = append(mws, layerMiddleware(name))
+		steps = append(steps, layerStep(name))
+	}
+	return mws, steps
+}
+
+var finalHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func BenchmarkComparison_MiddlewareChain(b *testing.B) {
+	mws, steps := layeredChains()
+
+	b.Run("Closure-Chain", func(b *testing.B) {
+		var h http.Handler
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h = buildMiddlewareChain(mws, finalHandler)
+		}
+		result = h
+	})
+
+	b.Run("Compiled", func(b *testing.B) {
+		var c compiledChain
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c = compileMiddleware(steps, finalHandler)
+		}
+		result = c.steps
+	})
+}
+
+func TestMiddlewareChains(t *testing.T) {
+	mws, steps := layeredChains()
+	compiled := compileMiddleware(steps, finalHandler)
+	handlers := map[string]http.Handler{
+		"buildMiddlewareChain": buildMiddlewareChain(mws, finalHandler),
+		"compileMiddleware":    &compiled,
+	}
+	for name, h := range handlers {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := rec.Header().Values("X-Layer"); !slices.Equal(got, layerNames) {
+			t.Errorf("%s: X-Layer = %v, want %v", name, got, layerNames)
+		}
+		if body := rec.Body.String(); body != "ok" {
+			t.Errorf("%s: body = %q, want ok", name, body)
+		}
+	}
+
+	stop := compileMiddleware([]middlewareStep{func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}}, finalHandler)
+	rec := httptest.NewRecorder()
+	stop.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized || rec.Body.Len() != 0 {
+		t.Errorf("stopped chain: code %d, body %q; want 401 and no body", rec.Code, rec.Body.String())
+	}
+
+	var h http.Handler
+	chainAllocs := testing.AllocsPerRun(100, func() {
+		h = buildMiddlewareChain(mws, finalHandler)
+	})
+	var c compiledChain
+	compiledAllocs := testing.AllocsPerRun(100, func() {
+		c = compileMiddleware(steps, finalHandler)
+	})
+	result = h
+	result = c.steps
+	if chainAllocs != float64(len(mws)) {
+		t.Errorf("buildMiddlewareChain allocs = %v, want %d (one closure per layer)", chainAllocs, len(mws))
+	}
+	if compiledAllocs != 0 {
+		t.Errorf("compileMiddleware allocs = %v, want 0", compiledAllocs)
+	}
+}
+
+func BenchmarkComparison_FuncValueArgument(b *testing.B) {
+	b.Run("Package-Func", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			passPackageFunc(8)
+		}
+		result = hookTotal
+	})
+
+	b.Run("Closure", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			passClosure(8, i)
+		}
+		result = hookTotal
+	})
+}
+
+func TestFuncValueArgument(t *testing.T) {
+	hookTotal = 0
+	passPackageFunc(4)
+	if hookTotal != 6 {
+		t.Errorf("passPackageFunc(4) total = %d, want 6", hookTotal)
+	}
+	hookTotal = 0
+	passClosure(4, 10)
+	if hookTotal != 46 {
+		t.Errorf("passClosure(4, 10) total = %d, want 46", hookTotal)
+	}
+
+	offset := 0
+	funcAllocs := testing.AllocsPerRun(100, func() { passPackageFunc(8) })
+	closureAllocs := testing.AllocsPerRun(100, func() { offset++; passClosure(8, offset) })
+	if funcAllocs != 0 {
+		t.Errorf("passPackageFunc allocs = %v, want 0", funcAllocs)
+	}
+	if closureAllocs != 1 {
+		t.Errorf("passClosure allocs = %v, want 1 (the closure)", closureAllocs)
+	}
+}
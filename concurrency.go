@@ -0,0 +1,473 @@
+package heapescapeanalysis
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// workerTask is the unit of work passed from Submit to a worker.
+type workerTask struct {
+	fn func()
+}
+
+// WorkerPool runs submitted functions on a fixed set of goroutines.
+//
+// Each submission travels to a worker as a *workerTask. Allocating one per
+// Submit makes every task a heap allocation; WorkerPool instead takes task
+// structs from a sync.Pool and recycles them once the worker has run the
+// function, so a steady stream of submissions does not allocate. A task
+// struct must not be touched after it is returned to the pool.
+type WorkerPool struct {
+	tasks    chan *workerTask
+	taskPool sync.Pool
+	recycle  bool
+	workers  sync.WaitGroup
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewWorkerPool starts workers goroutines that share a queue of up to queue
+// pending tasks.
+func NewWorkerPool(workers, queue int) *WorkerPool {
+	return newWorkerPool(workers, queue, true)
+}
+
+// newWorkerPool allows disabling task recycling for comparison benchmarks.
+func newWorkerPool(workers, queue int, recycle bool) *WorkerPool {
+	p := &WorkerPool{
+		tasks:   make(chan *workerTask, queue),
+		recycle: recycle,
+	}
+	p.taskPool.New = func() interface{} {
+		return &workerTask{}
+	}
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	defer p.workers.Done()
+	for t := range p.tasks {
+		t.fn()
+		if p.recycle {
+			t.fn = nil // Don't keep the function's captures alive in the pool
+			p.taskPool.Put(t)
+		}
+	}
+}
+
+// Submit queues fn to run on a worker, blocking while the queue is full.
+// Submit panics if called after Close.
+func (p *WorkerPool) Submit(fn func()) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		panic("heapescapeanalysis: Submit on closed WorkerPool")
+	}
+
+	var t *workerTask
+	if p.recycle {
+		t = p.taskPool.Get().(*workerTask)
+	} else {
+		t = &workerTask{} // One allocation per submission
+	}
+	t.fn = fn
+	p.tasks <- t
+}
+
+// Close stops accepting tasks and waits for all queued tasks to finish.
+// Calling Close more than once is a no-op.
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.tasks)
+	p.mu.Unlock()
+	p.workers.Wait()
+}
+
+// Passing slices to goroutines
+//
+// A goroutine can outlive the function that started it, so everything it
+// captures is moved to the heap, and escape analysis marks xs as leaking.
+// That decision propagates to callers: a slice they make locally and pass in
+// can no longer live on their stack, and its backing array stays alive for
+// as long as the goroutine runs, even after the caller has moved on. Doing
+// the work synchronously keeps xs non-escaping.
+
+//go:noinline
+func processSliceInGoroutine(xs []int) int {
+	done := make(chan int)
+	go func() {
+		done <- sumInts(xs) // Captures xs: its backing must outlive this frame
+	}()
+	return <-done
+}
+
+//go:noinline
+func processSliceSync(xs []int) int {
+	return sumInts(xs) // xs does not escape
+}
+
+// Capturing a mutex by pointer
+//
+// A sync.Mutex must never be copied after first use: a copy is a separate
+// lock, so code that locks the copy excludes nobody else. Capturing the
+// mutex by value in a closure (mu := c.mu) is an easy way to do this by
+// accident, and go vet's copylocks check reports it; see
+// testdata/mutexcopy for the broken version. Capturing a pointer to the
+// struct that owns the mutex is correct. The closure and the captured
+// pointer then escape to the heap, which is the price of sharing the lock.
+
+// guardedCounter is a counter protected by its own mutex.
+type guardedCounter struct {
+	mu sync.Mutex
+	n  int
+}
+
+//go:noinline
+func captureMutexByPointer(c *guardedCounter) func() {
+	return func() {
+		c.mu.Lock() // The one shared lock
+		c.n++
+		c.mu.Unlock()
+	}
+}
+
+// Lazy initialization with sync.Once versus eager package init
+//
+// A resource built at package init costs nothing per call, but every program
+// that imports the package pays for it up front, used or not. sync.Once
+// defers the allocation to the first caller and makes concurrent first calls
+// safe. After that, Do's inlined fast path is a single atomic load of the
+// done flag: no lock, no allocation, and close to the cost of reading a
+// package variable. The slow path, which takes the Once's mutex,
+// runs only until the first call completes.
+
+var (
+	lazyResourceOnce sync.Once
+	lazyResource     *LargeStruct
+
+	eagerResource = &LargeStruct{} // Allocated during package init
+)
+
+//go:noinline
+func lazyAlloc() *LargeStruct {
+	lazyResourceOnce.Do(func() {
+		lazyResource = &LargeStruct{} // Allocated by the first caller only
+	})
+	return lazyResource
+}
+
+//go:noinline
+func eagerAlloc() *LargeStruct {
+	return eagerResource
+}
+
+// Publishing configuration with atomic.Value versus a mutex
+//
+// Hot-reloadable configuration is usually copy-on-write: a writer builds a
+// complete new snapshot and publishes its pointer with atomic.Value.Store,
+// and readers Load whichever snapshot is current without taking a lock.
+// Every publish allocates its snapshot, because readers may still hold the
+// old one and it can never be modified in place. That is cheap when configs
+// change rarely and are read constantly. A mutex-guarded field can be
+// updated in place without allocating, but every reader then has to take
+// the lock and copy the fields it needs, so reads contend with each other
+// and with writers.
+
+// config is a snapshot of settings that must be read consistently: Version
+// and Limit are always updated together.
+type config struct {
+	Version int
+	Limit   int
+}
+
+// atomicConfig publishes immutable *config snapshots.
+type atomicConfig struct {
+	v atomic.Value
+}
+
+func (c *atomicConfig) load() *config {
+	return c.v.Load().(*config)
+}
+
+// mutexConfig guards a config updated in place.
+type mutexConfig struct {
+	mu  sync.Mutex
+	cfg config
+}
+
+func (c *mutexConfig) load() config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg
+}
+
+//go:noinline
+func atomicValueSwap(c *atomicConfig, version int) {
+	c.v.Store(&config{Version: version, Limit: version * 10}) // New snapshot per publish
+}
+
+//go:noinline
+func mutexFieldSwap(c *mutexConfig, version int) {
+	c.mu.Lock()
+	c.cfg = config{Version: version, Limit: version * 10} // Updated in place
+	c.mu.Unlock()
+}
+
+// defer mu.Unlock() versus unlocking by hand
+//
+// Since Go 1.14 a defer that runs at most once per call, outside any loop,
+// is open-coded: the compiler inlines the deferred call at each return and
+// sets a bit to record that it is pending, so defer allocates nothing and
+// costs about a nanosecond. That only matters when the critical section is
+// itself a few nanoseconds, like the increment below, and the function runs
+// millions of times per second. Anywhere the locked work does real I/O,
+// allocation or map access, the difference disappears in the noise, while
+// defer still guarantees the unlock on every return path and on panic.
+
+// lockedTotal is the counter both functions update under the caller's mutex.
+var lockedTotal int
+
+//go:noinline
+func withDeferUnlock(mu *sync.Mutex) {
+	mu.Lock()
+	defer mu.Unlock() // Open-coded: no allocation
+	lockedTotal++
+}
+
+//go:noinline
+func withManualUnlock(mu *sync.Mutex) {
+	mu.Lock()
+	lockedTotal++
+	mu.Unlock() // Skipped if the critical section panics
+}
+
+// time.After in a select loop versus a reused Timer
+//
+// time.After creates a new Timer, with its channel, on every call, so a
+// receive loop that guards each iteration with a timeout allocates a timer
+// per message even though almost none of them ever fire. Before Go 1.23 it
+// was worse: the runtime kept every pending timer alive until it fired, so
+// a loop with a one-minute timeout accumulated a minute's worth of timers.
+// Go 1.23 lets unreferenced timers be collected early, which removes the
+// leak but not the allocation. Creating one Timer up front and calling
+// Reset before each wait costs nothing per iteration; with the Go 1.23
+// semantics Reset and Stop also discard any stale expiry, so no draining of
+// t.C is needed.
+
+//go:noinline
+func usesTimeAfter(ch <-chan int, timeout time.Duration) (int, bool) {
+	select {
+	case v := <-ch:
+		return v, true
+	case <-time.After(timeout): // New timer and channel per call
+		return 0, false
+	}
+}
+
+//go:noinline
+func usesReusableTimer(ch <-chan int, t *time.Timer, timeout time.Duration) (int, bool) {
+	t.Reset(timeout) // Reuses t and its channel
+	select {
+	case v := <-ch:
+		t.Stop()
+		return v, true
+	case <-t.C:
+		return 0, false
+	}
+}
+
+// sync.Map versus a map guarded by a mutex
+//
+// sync.Map stores keys and values as interface{} (any), so storing an int
+// boxes it on the heap unless it is one of the small values the runtime
+// keeps preallocated. Each Store of a non-small key and value therefore
+// allocates twice for the boxes, and since Go 1.24 the map, a concurrent
+// hash trie, also allocates a new entry node for each Store, including
+// overwrites of an existing key. A plain map[int]int behind a mutex stores
+// the ints inline and allocates only when the table grows, so a steady
+// stream of overwrites allocates nothing; the cost is that every store
+// serializes on the one lock. sync.Map pays off for keys written once and
+// read many times by many goroutines, not as a general replacement.
+
+// mutexIntMap is a map[int]int safe for concurrent use.
+type mutexIntMap struct {
+	mu sync.Mutex
+	m  map[int]int
+}
+
+func newMutexIntMap() *mutexIntMap {
+	return &mutexIntMap{m: make(map[int]int)}
+}
+
+func (m *mutexIntMap) load(k int) (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.m[k]
+	return v, ok
+}
+
+//go:noinline
+func syncMapStore(m *sync.Map, k, v int) {
+	m.Store(k, v) // Boxes k and v, allocates an entry node
+}
+
+//go:noinline
+func mutexMapStore(m *mutexIntMap, k, v int) {
+	m.mu.Lock()
+	m.m[k] = v // Stored inline
+	m.mu.Unlock()
+}
+
+// Collecting parallel results by index versus through a channel of pointers
+//
+// A fan-out that sends each result back as a pointer allocates one heap
+// object per result: the goroutine builds it, and the compiler cannot know
+// how long the receiver will keep it. When the number of results is known
+// up front, the collector can allocate the whole output slice once and let
+// each worker write its own elements by index. Distinct elements of a slice
+// are distinct memory locations, so workers writing disjoint indexes do not
+// race, and wg.Wait makes all the writes visible to the collector. The
+// results then cost one allocation in total, plus the goroutines, which
+// both versions pay.
+
+// collectWorkers is the number of goroutines the collect functions fan out to.
+const collectWorkers = 4
+
+// parallelCollect returns the squares of 0..n-1, computed by collectWorkers
+// goroutines that each write a disjoint stripe of the result.
+//
+//go:noinline
+func parallelCollect(n int) []int {
+	out := make([]int, n) // The only allocation for results
+	var wg sync.WaitGroup
+	for w := 0; w < collectWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < n; i += collectWorkers {
+				out[i] = i * i // Each index has exactly one writer
+			}
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// indexedResult is a result sent back to collectViaPointerChannel.
+type indexedResult struct {
+	index, value int
+}
+
+// collectViaPointerChannel is parallelCollect with each result sent back as
+// a pointer over a channel.
+//
+//go:noinline
+func collectViaPointerChannel(n int) []int {
+	results := make(chan *indexedResult, collectWorkers)
+	for w := 0; w < collectWorkers; w++ {
+		go func() {
+			for i := w; i < n; i += collectWorkers {
+				results <- &indexedResult{index: i, value: i * i} // One allocation per result
+			}
+		}()
+	}
+	out := make([]int, n)
+	for range n {
+		r := <-results
+		out[r.index] = r.value
+	}
+	return out
+}
+
+// Counters owned by a goroutine versus atomic counters
+//
+// "Share memory by communicating" suggests giving the count to one goroutine
+// and sending it increments over a channel. Each send then costs a channel
+// operation: taking the channel's lock, copying the value and, when the
+// owner is parked, waking it, so every increment from every goroutine is
+// serialized through one lock plus a scheduler handoff. Sending int64 values
+// allocates nothing, but reading the count needs a reply channel, and a
+// fresh one per request is an allocation, as would be any message sent as
+// a pointer. An atomic.Int64 increments in a single instruction with no
+// goroutine, no lock and no allocation; under contention the cache line
+// still bounces between cores, but it is typically more than ten times
+// faster than the channel. A sync.Mutex falls between the two.
+// Channel-owned state pays off when the owner does real work per message,
+// such as batching writes or maintaining a structure too complex for
+// atomics, not for a counter.
+
+// channelCounter is a count owned by one goroutine and changed by messages.
+type channelCounter struct {
+	adds  chan int64
+	reads chan chan int64
+	done  chan struct{}
+}
+
+func newChannelCounter() *channelCounter {
+	c := &channelCounter{
+		adds:  make(chan int64, 64),
+		reads: make(chan chan int64),
+		done:  make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+func (c *channelCounter) run() {
+	var n int64
+	for {
+		select {
+		case d := <-c.adds:
+			n += d
+		case reply := <-c.reads:
+			// Apply increments already queued so a reader sees its own adds.
+			for len(c.adds) > 0 {
+				n += <-c.adds
+			}
+			reply <- n
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Add queues an increment of d; it is a value send and does not allocate.
+func (c *channelCounter) Add(d int64) {
+	c.adds <- d
+}
+
+// Load returns the count once every Add that returned before it was called
+// has been applied.
+func (c *channelCounter) Load() int64 {
+	reply := make(chan int64) // One allocation per read
+	c.reads <- reply
+	return <-reply
+}
+
+// Close stops the owning goroutine.
+func (c *channelCounter) Close() {
+	close(c.done)
+}
+
+// atomicCounter is a count updated with atomic instructions.
+type atomicCounter struct {
+	n atomic.Int64
+}
+
+func (c *atomicCounter) Add(d int64) {
+	c.n.Add(d) // One atomic add, no goroutine or lock
+}
+
+func (c *atomicCounter) Load() int64 {
+	return c.n.Load()
+}
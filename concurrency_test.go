@@ -0,0 +1,650 @@
+package heapescapeanalysis
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Several tests in this file start goroutines that share state. Their own
+// checks catch lost or mixed-up updates, but a racy program can still get
+// the right answer by luck, so run them with -race as well: the detector
+// reports unsynchronized access even when the result is correct.
+
+var workerTaskRuns atomic.Int64
+
+func countWorkerTask() {
+	workerTaskRuns.Add(1)
+}
+
+func BenchmarkComparison_WorkerPoolTasks(b *testing.B) {
+	for _, bc := range []struct {
+		name    string
+		recycle bool
+	}{
+		{"Pooled-Tasks", true},
+		{"Allocated-Tasks", false},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			p := newWorkerPool(4, 64, bc.recycle)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.Submit(countWorkerTask)
+			}
+			p.Close()
+		})
+	}
+}
+
+func TestWorkerPoolConcurrentSubmit(t *testing.T) {
+	const submitters, perSubmitter = 8, 500
+
+	p := NewWorkerPool(4, 16)
+	var ran atomic.Int64
+	var wg sync.WaitGroup
+	for s := 0; s < submitters; s++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perSubmitter; i++ {
+				p.Submit(func() { ran.Add(1) })
+			}
+		}()
+	}
+	wg.Wait()
+	p.Close()
+	p.Close()
+
+	if got := ran.Load(); got != submitters*perSubmitter {
+		t.Errorf("ran %d tasks, want %d", got, submitters*perSubmitter)
+	}
+}
+
+func TestWorkerPoolSubmitAfterClose(t *testing.T) {
+	p := NewWorkerPool(1, 1)
+	p.Close()
+	defer func() {
+		if recover() == nil {
+			t.Error("Submit after Close did not panic")
+		}
+	}()
+	p.Submit(func() {})
+}
+
+func fillSequence(xs []int) {
+	for i := range xs {
+		xs[i] = i
+	}
+}
+
+func BenchmarkComparison_SliceToGoroutine(b *testing.B) {
+	b.Run("Goroutine", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			xs := make([]int, 64) // Escapes: passed to a goroutine
+			fillSequence(xs)
+			r = processSliceInGoroutine(xs)
+		}
+		result = r
+	})
+
+	b.Run("Synchronous", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			xs := make([]int, 64) // Stays on the stack
+			fillSequence(xs)
+			r = processSliceSync(xs)
+		}
+		result = r
+	})
+}
+
+func TestSliceToGoroutine(t *testing.T) {
+	xs := make([]int, 64)
+	fillSequence(xs)
+	if got, want := processSliceInGoroutine(xs), processSliceSync(xs); got != want || want != 63*64/2 {
+		t.Errorf("processSliceInGoroutine = %d, processSliceSync = %d, want %d", got, want, 63*64/2)
+	}
+
+	var r int
+	allocs := testing.AllocsPerRun(100, func() {
+		xs := make([]int, 64)
+		fillSequence(xs)
+		r = processSliceSync(xs)
+	})
+	result = r
+	if allocs != 0 {
+		t.Errorf("processSliceSync with a local slice allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkCaptureMutexByPointer(b *testing.B) {
+	c := &guardedCounter{}
+	var inc func()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		inc = captureMutexByPointer(c)
+		inc()
+	}
+	result = c.n
+}
+
+func TestCaptureMutexByPointer(t *testing.T) {
+	const goroutines, increments = 8, 1000
+
+	c := &guardedCounter{}
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inc := captureMutexByPointer(c)
+			for i := 0; i < increments; i++ {
+				inc()
+			}
+		}()
+	}
+	wg.Wait()
+	if c.n != goroutines*increments {
+		t.Errorf("counter = %d, want %d", c.n, goroutines*increments)
+	}
+}
+
+func TestCaptureMutexByValueIsFlagged(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go vet")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	out, err := exec.Command("go", "vet", "./testdata/mutexcopy").CombinedOutput()
+	if err == nil {
+		t.Fatal("go vet accepted a closure that copies a sync.Mutex")
+	}
+	if !strings.Contains(string(out), "copies lock value") {
+		t.Errorf("go vet output does not mention the lock copy:\n%s", out)
+	}
+}
+
+func BenchmarkComparison_LazyInit(b *testing.B) {
+	b.Run("Once", func(b *testing.B) {
+		var r *LargeStruct
+		lazyAlloc()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r = lazyAlloc()
+		}
+		result = r
+	})
+
+	b.Run("Eager", func(b *testing.B) {
+		var r *LargeStruct
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = eagerAlloc()
+		}
+		result = r
+	})
+}
+
+// TestLazyAllocConcurrent makes concurrent first calls to lazyAlloc and
+// checks they all see the same resource.
+func TestLazyAllocConcurrent(t *testing.T) {
+	const goroutines = 16
+
+	var wg sync.WaitGroup
+	got := make([]*LargeStruct, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[g] = lazyAlloc()
+		}()
+	}
+	wg.Wait()
+
+	for g, r := range got {
+		if r == nil || r != got[0] {
+			t.Fatalf("goroutine %d got %p, goroutine 0 got %p; want one shared resource", g, r, got[0])
+		}
+	}
+	if eagerAlloc() == nil {
+		t.Error("eagerAlloc() = nil")
+	}
+
+	var r *LargeStruct
+	allocs := testing.AllocsPerRun(100, func() { r = lazyAlloc() })
+	result = r
+	if allocs != 0 {
+		t.Errorf("lazyAlloc allocs after first use = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_ConfigSwap(b *testing.B) {
+	b.Run("Atomic-Value-Read", func(b *testing.B) {
+		var c atomicConfig
+		atomicValueSwap(&c, 1)
+		var total atomic.Int64
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			var limit int
+			for pb.Next() {
+				limit += c.load().Limit
+			}
+			total.Add(int64(limit))
+		})
+		result = total.Load()
+	})
+
+	b.Run("Mutex-Read", func(b *testing.B) {
+		var c mutexConfig
+		mutexFieldSwap(&c, 1)
+		var total atomic.Int64
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			var limit int
+			for pb.Next() {
+				limit += c.load().Limit
+			}
+			total.Add(int64(limit))
+		})
+		result = total.Load()
+	})
+
+	b.Run("Atomic-Value-Write", func(b *testing.B) {
+		var c atomicConfig
+		var version atomic.Int64
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				atomicValueSwap(&c, int(version.Add(1)))
+			}
+		})
+	})
+
+	b.Run("Mutex-Write", func(b *testing.B) {
+		var c mutexConfig
+		var version atomic.Int64
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				mutexFieldSwap(&c, int(version.Add(1)))
+			}
+		})
+	})
+}
+
+// TestConfigSwapConcurrent checks that readers always see a Version and
+// Limit published together.
+func TestConfigSwapConcurrent(t *testing.T) {
+	const readers, writes = 4, 1000
+
+	var ac atomicConfig
+	var mc mutexConfig
+	atomicValueSwap(&ac, 0)
+	mutexFieldSwap(&mc, 0)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	var torn atomic.Int64
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if a := ac.load(); a.Limit != a.Version*10 {
+					torn.Add(1)
+				}
+				if m := mc.load(); m.Limit != m.Version*10 {
+					torn.Add(1)
+				}
+			}
+		}()
+	}
+	for v := 1; v <= writes; v++ {
+		atomicValueSwap(&ac, v)
+		mutexFieldSwap(&mc, v)
+	}
+	close(stop)
+	wg.Wait()
+
+	if n := torn.Load(); n != 0 {
+		t.Errorf("readers saw %d inconsistent configs", n)
+	}
+	if ac.load().Version != writes || mc.load().Version != writes {
+		t.Errorf("final versions = %d and %d, want %d", ac.load().Version, mc.load().Version, writes)
+	}
+
+	v := writes
+	if allocs := testing.AllocsPerRun(100, func() { v++; mutexFieldSwap(&mc, v) }); allocs != 0 {
+		t.Errorf("mutexFieldSwap allocs = %v, want 0", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { v++; atomicValueSwap(&ac, v) }); allocs != 1 {
+		t.Errorf("atomicValueSwap allocs = %v, want 1 (the snapshot)", allocs)
+	}
+}
+
+func BenchmarkComparison_DeferUnlock(b *testing.B) {
+	b.Run("Defer", func(b *testing.B) {
+		var mu sync.Mutex
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			withDeferUnlock(&mu)
+		}
+	})
+
+	b.Run("Manual", func(b *testing.B) {
+		var mu sync.Mutex
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			withManualUnlock(&mu)
+		}
+	})
+}
+
+// TestDeferUnlockConcurrent checks that both functions serialize their
+// updates on the shared mutex.
+func TestDeferUnlockConcurrent(t *testing.T) {
+	const goroutines, calls = 8, 1000
+
+	var mu sync.Mutex
+	lockedTotal = 0
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				if g%2 == 0 {
+					withDeferUnlock(&mu)
+				} else {
+					withManualUnlock(&mu)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if lockedTotal != goroutines*calls {
+		t.Errorf("lockedTotal = %d, want %d", lockedTotal, goroutines*calls)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { withDeferUnlock(&mu) }); allocs != 0 {
+		t.Errorf("withDeferUnlock allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_TimeoutTimer(b *testing.B) {
+	b.Run("Time-After", func(b *testing.B) {
+		ch := make(chan int, 1)
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ch <- i
+			r, _ = usesTimeAfter(ch, time.Minute)
+		}
+		result = r
+	})
+
+	b.Run("Reused-Timer", func(b *testing.B) {
+		ch := make(chan int, 1)
+		t := time.NewTimer(time.Minute)
+		defer t.Stop()
+		var r int
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ch <- i
+			r, _ = usesReusableTimer(ch, t, time.Minute)
+		}
+		result = r
+	})
+}
+
+func TestReusableTimerFires(t *testing.T) {
+	ch := make(chan int, 1)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	ch <- 7
+	if v, ok := usesReusableTimer(ch, timer, time.Hour); !ok || v != 7 {
+		t.Errorf("usesReusableTimer with a ready value = %d, %v; want 7, true", v, ok)
+	}
+	for i := 0; i < 2; i++ {
+		if _, ok := usesReusableTimer(ch, timer, time.Millisecond); ok {
+			t.Fatalf("wait %d: usesReusableTimer on an empty channel did not time out", i)
+		}
+	}
+	if _, ok := usesTimeAfter(ch, time.Millisecond); ok {
+		t.Error("usesTimeAfter on an empty channel did not time out")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ch <- 1
+		usesReusableTimer(ch, timer, time.Hour)
+	})
+	if allocs != 0 {
+		t.Errorf("usesReusableTimer allocs = %v, want 0", allocs)
+	}
+}
+
+// storeKeys is the number of distinct keys the map store benchmarks cycle
+// through; the keys start above the runtime's cache of small boxed ints.
+const storeKeys = 1024
+
+func BenchmarkComparison_ConcurrentMapStore(b *testing.B) {
+	b.Run("Sync-Map", func(b *testing.B) {
+		var m sync.Map
+		for k := 0; k < storeKeys; k++ {
+			syncMapStore(&m, 1000+k, k)
+		}
+		var next atomic.Int64
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				n := int(next.Add(1))
+				syncMapStore(&m, 1000+n%storeKeys, 1000+n)
+			}
+		})
+	})
+
+	b.Run("Mutex-Map", func(b *testing.B) {
+		m := newMutexIntMap()
+		for k := 0; k < storeKeys; k++ {
+			mutexMapStore(m, 1000+k, k)
+		}
+		var next atomic.Int64
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				n := int(next.Add(1))
+				mutexMapStore(m, 1000+n%storeKeys, 1000+n)
+			}
+		})
+	})
+}
+
+// TestConcurrentMapStore gives each writer its own range of keys and checks
+// that every key holds its writer's last value.
+func TestConcurrentMapStore(t *testing.T) {
+	const writers, perWriter = 4, 500
+
+	var sm sync.Map
+	mm := newMutexIntMap()
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for round := 0; round < 3; round++ {
+				for i := 0; i < perWriter; i++ {
+					k := 1000 + w*perWriter + i
+					syncMapStore(&sm, k, k*10+round)
+					mutexMapStore(mm, k, k*10+round)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for k := 1000; k < 1000+writers*perWriter; k++ {
+		want := k*10 + 2
+		if v, ok := sm.Load(k); !ok || v.(int) != want {
+			t.Fatalf("sync.Map[%d] = %v, %v; want %d", k, v, ok, want)
+		}
+		if v, ok := mm.load(k); !ok || v != want {
+			t.Fatalf("mutexIntMap[%d] = %d, %v; want %d", k, v, ok, want)
+		}
+	}
+
+	if raceEnabled {
+		return
+	}
+	v := 1 << 20
+	syncAllocs := testing.AllocsPerRun(100, func() { v++; syncMapStore(&sm, 1000, v) })
+	mutexAllocs := testing.AllocsPerRun(100, func() { v++; mutexMapStore(mm, 1000, v) })
+	if syncAllocs < 2 {
+		t.Errorf("syncMapStore allocs = %v, want at least 2 (boxed key and value)", syncAllocs)
+	}
+	if mutexAllocs != 0 {
+		t.Errorf("mutexMapStore allocs = %v, want 0 for an existing key", mutexAllocs)
+	}
+}
+
+func BenchmarkComparison_ParallelCollect(b *testing.B) {
+	b.Run("Shared-Slice", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = parallelCollect(100)
+		}
+		result = r
+	})
+
+	b.Run("Pointer-Channel", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = collectViaPointerChannel(100)
+		}
+		result = r
+	})
+}
+
+// TestParallelCollect has workers write disjoint indexes of one slice,
+// which the race detector must not report.
+func TestParallelCollect(t *testing.T) {
+	for _, n := range []int{0, 1, collectWorkers - 1, 100, 1001} {
+		shared := parallelCollect(n)
+		viaChannel := collectViaPointerChannel(n)
+		if len(shared) != n {
+			t.Fatalf("parallelCollect(%d) returned %d results", n, len(shared))
+		}
+		for i, v := range shared {
+			if v != i*i {
+				t.Fatalf("parallelCollect(%d)[%d] = %d, want %d", n, i, v, i*i)
+			}
+		}
+		if !slices.Equal(shared, viaChannel) {
+			t.Errorf("n=%d: parallelCollect and collectViaPointerChannel differ", n)
+		}
+	}
+
+	if raceEnabled {
+		return
+	}
+	var r []int
+	shared := testing.AllocsPerRun(20, func() { r = parallelCollect(100) })
+	viaChannel := testing.AllocsPerRun(20, func() { r = collectViaPointerChannel(100) })
+	result = r
+	if viaChannel-shared < 100 {
+		t.Errorf("collectViaPointerChannel allocs = %v, parallelCollect = %v, want at least 100 more (one per result)", viaChannel, shared)
+	}
+}
+
+func BenchmarkComparison_ContendedCounter(b *testing.B) {
+	b.Run("Channel", func(b *testing.B) {
+		c := newChannelCounter()
+		defer c.Close()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.Add(1)
+			}
+		})
+		result = c.Load()
+	})
+
+	b.Run("Atomic", func(b *testing.B) {
+		var c atomicCounter
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.Add(1)
+			}
+		})
+		result = c.Load()
+	})
+}
+
+// TestCountersConcurrent checks that every increment from every goroutine
+// is counted exactly once.
+func TestCountersConcurrent(t *testing.T) {
+	const workers, adds = 8, 1000
+
+	cc := newChannelCounter()
+	defer cc.Close()
+	var ac atomicCounter
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < adds; i++ {
+				cc.Add(2)
+				ac.Add(2)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, want := cc.Load(), int64(workers*adds*2); got != want {
+		t.Errorf("channelCounter = %d, want %d", got, want)
+	}
+	if got, want := ac.Load(), int64(workers*adds*2); got != want {
+		t.Errorf("atomicCounter = %d, want %d", got, want)
+	}
+
+	if raceEnabled {
+		return
+	}
+	var n int64
+	if allocs := testing.AllocsPerRun(100, func() { cc.Add(1) }); allocs != 0 {
+		t.Errorf("channelCounter.Add allocs = %v, want 0", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { n = cc.Load() }); allocs != 1 {
+		t.Errorf("channelCounter.Load allocs = %v, want 1 (the reply channel)", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { ac.Add(1); n = ac.Load() }); allocs != 0 {
+		t.Errorf("atomicCounter allocs = %v, want 0", allocs)
+	}
+	result = n
+}
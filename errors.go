@@ -0,0 +1,115 @@
+package heapescapeanalysis
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+)
+
+// Errors that capture a stack trace
+//
+// An error that records where it was created has to allocate on every error
+// path: the error value escapes to the caller, and so does the slice of
+// program counters copied out of runtime.Callers. Walking the stack is the
+// larger cost in time, and symbolizing the PCs into frames allocates more
+// again when the trace is finally printed. That is affordable for rare
+// failures, but on paths where errors are expected (not found, EOF, retry)
+// a sentinel error such as errNotFound costs nothing: it is allocated once
+// at init and returned by reference.
+
+// maxStackDepth bounds the number of frames stackError records.
+const maxStackDepth = 32
+
+// stackError is an error annotated with the call stack that created it.
+type stackError struct {
+	msg string
+	pcs []uintptr
+}
+
+func (e *stackError) Error() string { return e.msg }
+
+// Frames returns the recorded call stack, innermost frame first.
+func (e *stackError) Frames() []runtime.Frame {
+	var frames []runtime.Frame
+	it := runtime.CallersFrames(e.pcs)
+	for {
+		f, more := it.Next()
+		frames = append(frames, f)
+		if !more {
+			return frames
+		}
+	}
+}
+
+var errNotFound = errors.New("not found")
+
+//go:noinline
+func errorWithStack() error {
+	var buf [maxStackDepth]uintptr
+	n := runtime.Callers(1, buf[:]) // Skip runtime.Callers itself
+	// The error escapes along with its copy of the PCs
+	return &stackError{
+		msg: "not found",
+		pcs: append([]uintptr(nil), buf[:n]...),
+	}
+}
+
+//go:noinline
+func lightweightError() error {
+	return errNotFound // Shared sentinel: no allocation
+}
+
+// Collecting validation errors into a presized slice
+//
+// A validator that reports every failure, not just the first, appends one
+// error per bad input. Starting from a nil slice, those appends grow it
+// through the usual doubling steps, so 100 failures cost half a dozen
+// allocations of the slice on top of the errors themselves. The number of
+// inputs is a known upper bound on the number of errors, so the slice can be
+// allocated once with that capacity. When failures are rare, presizing
+// wastes the unused capacity instead; a nil slice then costs nothing on the
+// happy path.
+
+// validationError reports an input that is not a lowercase identifier.
+type validationError struct {
+	Input string
+}
+
+func (e *validationError) Error() string {
+	return "invalid identifier " + strconv.Quote(e.Input)
+}
+
+// validIdentifier reports whether s is a non-empty run of a-z.
+func validIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'a' || s[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+//go:noinline
+func collectErrorsNaive(inputs []string) []error {
+	var errs []error
+	for _, in := range inputs {
+		if !validIdentifier(in) {
+			errs = append(errs, &validationError{Input: in}) // Regrows errs
+		}
+	}
+	return errs
+}
+
+//go:noinline
+func collectErrorsPrealloc(inputs []string) []error {
+	errs := make([]error, 0, len(inputs)) // Upper bound: every input fails
+	for _, in := range inputs {
+		if !validIdentifier(in) {
+			errs = append(errs, &validationError{Input: in})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,119 @@
+package heapescapeanalysis
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func BenchmarkComparison_ErrorStack(b *testing.B) {
+	b.Run("With-Stack", func(b *testing.B) {
+		var r error
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = errorWithStack()
+		}
+		result = r
+	})
+
+	b.Run("Sentinel", func(b *testing.B) {
+		var r error
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = lightweightError()
+		}
+		result = r
+	})
+}
+
+func TestErrorWithStack(t *testing.T) {
+	err := errorWithStack()
+	var se *stackError
+	if !errors.As(err, &se) {
+		t.Fatalf("errorWithStack() = %T, want *stackError", err)
+	}
+	frames := se.Frames()
+	if len(frames) < 2 {
+		t.Fatalf("got %d frames, want at least 2", len(frames))
+	}
+	if !strings.HasSuffix(frames[0].Function, ".errorWithStack") {
+		t.Errorf("innermost frame = %s, want errorWithStack", frames[0].Function)
+	}
+	if !strings.HasSuffix(frames[1].Function, ".TestErrorWithStack") {
+		t.Errorf("caller frame = %s, want TestErrorWithStack", frames[1].Function)
+	}
+
+	if lightweightError() != errNotFound {
+		t.Error("lightweightError did not return errNotFound")
+	}
+	var r error
+	stackAllocs := testing.AllocsPerRun(100, func() { r = errorWithStack() })
+	sentinelAllocs := testing.AllocsPerRun(100, func() { r = lightweightError() })
+	result = r
+	if stackAllocs < 2 {
+		t.Errorf("errorWithStack allocs = %v, want the error and its PCs on the heap", stackAllocs)
+	}
+	if sentinelAllocs != 0 {
+		t.Errorf("lightweightError allocs = %v, want 0", sentinelAllocs)
+	}
+}
+
+// invalidInputs returns n inputs that all fail validIdentifier.
+func invalidInputs(n int) []string {
+	inputs := make([]string, n)
+	for i := range inputs {
+		inputs[i] = "Item-" + strconv.Itoa(i)
+	}
+	return inputs
+}
+
+func BenchmarkComparison_CollectErrors(b *testing.B) {
+	inputs := invalidInputs(100)
+
+	b.Run("Nil-Slice", func(b *testing.B) {
+		var r []error
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = collectErrorsNaive(inputs)
+		}
+		result = r
+	})
+
+	b.Run("Presized", func(b *testing.B) {
+		var r []error
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = collectErrorsPrealloc(inputs)
+		}
+		result = r
+	})
+}
+
+func TestCollectErrors(t *testing.T) {
+	inputs := append(invalidInputs(10), "valid", "", "alsovalid")
+	naive, prealloc := collectErrorsNaive(inputs), collectErrorsPrealloc(inputs)
+	if len(naive) != 11 || len(prealloc) != 11 {
+		t.Fatalf("got %d and %d errors, want 11", len(naive), len(prealloc))
+	}
+	for i := range naive {
+		if naive[i].Error() != prealloc[i].Error() {
+			t.Errorf("error %d: %q versus %q", i, naive[i], prealloc[i])
+		}
+	}
+	if got := collectErrorsNaive([]string{"ok"}); got != nil {
+		t.Errorf("collectErrorsNaive(valid) = %v, want nil", got)
+	}
+
+	all := invalidInputs(100)
+	var r []error
+	naiveAllocs := testing.AllocsPerRun(20, func() { r = collectErrorsNaive(all) })
+	preallocAllocs := testing.AllocsPerRun(20, func() { r = collectErrorsPrealloc(all) })
+	result = r
+	if preallocAllocs != 101 {
+		t.Errorf("collectErrorsPrealloc allocs = %v, want 101 (the slice and one per error)", preallocAllocs)
+	}
+	if naiveAllocs <= preallocAllocs {
+		t.Errorf("collectErrorsNaive allocs = %v, want more than %v", naiveAllocs, preallocAllocs)
+	}
+}
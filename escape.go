@@ -0,0 +1,119 @@
+package heapescapeanalysis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EscapeNote is a single diagnostic printed by the compiler's escape
+// analysis (go build -gcflags=-m), such as "moved to heap: x".
+type EscapeNote struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String formats n as the compiler does: "file:line:col: message". If the
+// message spans several lines, the extra lines follow unprefixed.
+func (n EscapeNote) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", n.File, n.Line, n.Column, n.Message)
+}
+
+// FormatNotes formats notes one per line, in the given order, so that
+// ParseEscapeNotes(FormatNotes(notes)) returns notes unchanged.
+func FormatNotes(notes []EscapeNote) string {
+	var sb strings.Builder
+	for _, n := range notes {
+		sb.WriteString(n.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// escapeLineRE matches a diagnostic line: "file:line:col: message". The
+// file part is matched lazily so Windows paths such as C:\x.go still work.
+var escapeLineRE = regexp.MustCompile(`^(.+?):(\d+):(\d+): (.*)$`)
+
+// ParseEscapeNotes parses the output of go build -gcflags=-m (at any
+// verbosity). Package header lines starting with "#" and blank lines are
+// skipped. A line without a position prefix continues the message of the
+// previous note, joined with a newline. Notes keep their input order.
+func ParseEscapeNotes(r io.Reader) ([]EscapeNote, error) {
+	var notes []EscapeNote
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20) // -m=2 inlining notes can be long
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := escapeLineRE.FindStringSubmatch(line)
+		if m == nil {
+			if len(notes) > 0 {
+				last := &notes[len(notes)-1]
+				last.Message += "\n" + line
+			}
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		notes = append(notes, EscapeNote{File: m[1], Line: lineNo, Column: col, Message: m[4]})
+	}
+	return notes, sc.Err()
+}
+
+// inlineDeclRE matches the inlining notes the compiler prints at each
+// function declaration, capturing the function name.
+var inlineDeclRE = regexp.MustCompile(`^(?:can|cannot) inline ([^\s:]+)`)
+
+// closureNameRE matches compiler names for function literals, which are
+// declared inside another function rather than starting a new one.
+var closureNameRE = regexp.MustCompile(`\.func\d+(\.\d+)*$`)
+
+// notesForFunc returns the notes that belong to function fn, in their
+// original order. A note belongs to fn if its message names it (as -m=2 does
+// with "... in fn:") or if it lies between fn's declaration and the next
+// declaration in the same file, using the "can inline"/"cannot inline"
+// notes as declaration markers.
+func notesForFunc(notes []EscapeNote, fn string) []EscapeNote {
+	type decl struct {
+		line int
+		name string
+	}
+	declsByFile := make(map[string][]decl)
+	for _, n := range notes {
+		m := inlineDeclRE.FindStringSubmatch(n.Message)
+		if m == nil || closureNameRE.MatchString(m[1]) {
+			continue
+		}
+		declsByFile[n.File] = append(declsByFile[n.File], decl{n.Line, m[1]})
+	}
+	for _, decls := range declsByFile {
+		sort.Slice(decls, func(i, j int) bool { return decls[i].line < decls[j].line })
+	}
+
+	// owner returns the function declared closest above note n
+	owner := func(n EscapeNote) string {
+		decls := declsByFile[n.File]
+		i := sort.Search(len(decls), func(i int) bool { return decls[i].line > n.Line })
+		if i == 0 {
+			return ""
+		}
+		return decls[i-1].name
+	}
+
+	marker := " in " + fn + ":"
+	var out []EscapeNote
+	for _, n := range notes {
+		if strings.Contains(n.Message, marker) || owner(n) == fn {
+			out = append(out, n)
+		}
+	}
+	return out
+}
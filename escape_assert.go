@@ -0,0 +1,36 @@
+package heapescapeanalysis
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// AssertNoEscape fails t if the compiler reports that variable varName of
+// function fn in package pkg escapes, i.e. if there is a "moved to heap:
+// varName" or "varName escapes to heap" note for it. Unlike allocation
+// counts, this checks the compiler's decision directly.
+//
+// The test is skipped if no go toolchain is available.
+func AssertNoEscape(t testing.TB, pkg, fn, varName string) {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	notes, err := RunEscapeAnalysis(pkg)
+	if err != nil {
+		t.Fatalf("escape analysis of %s: %v", pkg, err)
+	}
+	for _, n := range notesForFunc(notes, fn) {
+		if varEscapes(n.Message, varName) {
+			t.Errorf("%s in %s escapes to the heap: %s", varName, fn, n)
+			return
+		}
+	}
+}
+
+// varEscapes reports whether msg is an escape note for varName.
+func varEscapes(msg, varName string) bool {
+	return msg == "moved to heap: "+varName ||
+		strings.HasPrefix(msg, varName+" escapes to heap")
+}
@@ -0,0 +1,50 @@
+package heapescapeanalysis
+
+import (
+	"fmt"
+	"testing"
+)
+
+// recordingTB captures failures instead of failing the real test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertNoEscape(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the compiler")
+	}
+	AssertNoEscape(t, ".", "returnValue", "x")
+
+	rec := &recordingTB{TB: t}
+	AssertNoEscape(rec, ".", "returnPointer", "x")
+	if len(rec.errors) != 1 {
+		t.Errorf("AssertNoEscape(returnPointer, x) reported %d failures, want 1: %q", len(rec.errors), rec.errors)
+	}
+}
+
+func TestVarEscapes(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"moved to heap: x", true},
+		{"x escapes to heap", true},
+		{"x escapes to heap in returnPointer:", true},
+		{"moved to heap: xs", false},
+		{"xs escapes to heap", false},
+		{"x does not escape", false},
+	}
+	for _, tt := range tests {
+		if got := varEscapes(tt.msg, "x"); got != tt.want {
+			t.Errorf("varEscapes(%q, x) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
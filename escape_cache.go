@@ -0,0 +1,128 @@
+package heapescapeanalysis
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"go/build"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CachedAnalyzer memoizes RunEscapeAnalysis per package path. Each result is
+// stored with a hash of the package's non-test .go files and reused until
+// those files change, so a watch loop that re-analyzes on every tick only
+// invokes the compiler after an edit. Hashing the sources is far cheaper
+// than even a cached go build, which still has to start the go command.
+//
+// Only the package's own directory is tracked: changes to dependencies,
+// go.mod or the toolchain are not detected, so call Clear after those.
+// Failed analyses are not cached. A CachedAnalyzer is safe for concurrent
+// use; calls are serialized while the compiler runs.
+type CachedAnalyzer struct {
+	mu      sync.Mutex
+	entries map[string]analysisEntry
+
+	run     func(pkgPath string) ([]EscapeNote, error)
+	resolve func(pkgPath string) (string, error)
+}
+
+type analysisEntry struct {
+	dir         string
+	fingerprint [sha256.Size]byte
+	notes       []EscapeNote
+}
+
+// NewCachedAnalyzer returns an empty CachedAnalyzer.
+func NewCachedAnalyzer() *CachedAnalyzer {
+	return &CachedAnalyzer{run: RunEscapeAnalysis, resolve: packageDir}
+}
+
+// Analyze returns the escape notes for pkgPath, running the compiler only
+// if there is no cached result or the package sources changed since it was
+// computed. The returned slice is the caller's to modify.
+func (a *CachedAnalyzer) Analyze(pkgPath string) ([]EscapeNote, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if e, ok := a.entries[pkgPath]; ok {
+		if fp, err := sourceFingerprint(e.dir); err == nil && fp == e.fingerprint {
+			return slices.Clone(e.notes), nil
+		}
+	}
+
+	dir, err := a.resolve(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	// Hash before compiling, so an edit made during the build invalidates
+	// the entry rather than being cached under the new contents.
+	fp, err := sourceFingerprint(dir)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := a.run(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	if a.entries == nil {
+		a.entries = make(map[string]analysisEntry)
+	}
+	a.entries[pkgPath] = analysisEntry{dir: dir, fingerprint: fp, notes: notes}
+	return slices.Clone(notes), nil
+}
+
+// Clear drops every cached result.
+func (a *CachedAnalyzer) Clear() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	clear(a.entries)
+}
+
+// packageDir returns the source directory of pkgPath. Relative (./x, ../x)
+// and absolute paths name directories directly; import paths are resolved
+// with go list.
+func packageDir(pkgPath string) (string, error) {
+	if build.IsLocalImport(pkgPath) || filepath.IsAbs(pkgPath) {
+		return filepath.Abs(pkgPath)
+	}
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkgPath).Output()
+	if err != nil {
+		return "", fmt.Errorf("go list %s: %v", pkgPath, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// sourceFingerprint hashes the names and contents of the non-test .go files
+// in dir.
+func sourceFingerprint(dir string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return sum, err
+	}
+	h := sha256.New()
+	for _, e := range entries { // ReadDir sorts by name
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return sum, err
+		}
+		fmt.Fprintf(h, "%s\x00", name)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return sum, err
+		}
+		h.Write([]byte{0})
+	}
+	h.Sum(sum[:0])
+	return sum, nil
+}
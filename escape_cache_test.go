@@ -0,0 +1,135 @@
+package heapescapeanalysis
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// countingAnalyzer returns a CachedAnalyzer whose compiler runs are counted
+// instead of executed.
+func countingAnalyzer(runs *int) *CachedAnalyzer {
+	a := NewCachedAnalyzer()
+	a.run = func(pkgPath string) ([]EscapeNote, error) {
+		*runs++
+		return []EscapeNote{{File: "a.go", Line: *runs, Message: "moved to heap: x"}}, nil
+	}
+	return a
+}
+
+func writeSource(t *testing.T, dir, name, src string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCachedAnalyzerHit(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "a.go", "package a\n")
+
+	var runs int
+	a := countingAnalyzer(&runs)
+	first, err := a.Analyze(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first[0].Message = "modified by caller"
+	second, err := a.Analyze(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("compiler ran %d times for two unchanged analyses, want 1", runs)
+	}
+	if second[0].Message != "moved to heap: x" {
+		t.Errorf("cached notes were changed through a returned slice: %q", second[0].Message)
+	}
+
+	// Test files are not part of the build
+	writeSource(t, dir, "a_test.go", "package a\n")
+	if _, err := a.Analyze(dir); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("adding a test file re-ran the compiler (%d runs), want 1", runs)
+	}
+}
+
+func TestCachedAnalyzerInvalidation(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "a.go", "package a\n")
+
+	var runs int
+	a := countingAnalyzer(&runs)
+	if _, err := a.Analyze(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	writeSource(t, dir, "a.go", "package a\n\nvar x int\n")
+	notes, err := a.Analyze(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 || notes[0].Line != 2 {
+		t.Errorf("after editing a.go: %d runs, notes from run %d; want 2 and 2", runs, notes[0].Line)
+	}
+
+	writeSource(t, dir, "b.go", "package a\n")
+	if _, err := a.Analyze(dir); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 3 {
+		t.Errorf("after adding b.go: %d runs, want 3", runs)
+	}
+
+	a.Clear()
+	if _, err := a.Analyze(dir); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 4 {
+		t.Errorf("after Clear: %d runs, want 4", runs)
+	}
+}
+
+func TestCachedAnalyzerErrorsNotCached(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "a.go", "package a\n")
+
+	var runs int
+	a := NewCachedAnalyzer()
+	a.run = func(pkgPath string) ([]EscapeNote, error) {
+		runs++
+		return nil, errors.New("build failed")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := a.Analyze(dir); err == nil {
+			t.Fatal("Analyze returned nil error for a failing build")
+		}
+	}
+	if runs != 2 {
+		t.Errorf("compiler ran %d times for two failing analyses, want 2", runs)
+	}
+}
+
+func TestCachedAnalyzerRealPackage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the compiler")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	a := NewCachedAnalyzer()
+	notes, err := a.Analyze(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notesForFunc(notes, "returnPointer")) == 0 {
+		t.Error("no notes reported for returnPointer")
+	}
+	if _, ok := a.entries["."]; !ok {
+		t.Error("result for . was not cached")
+	}
+}
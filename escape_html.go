@@ -0,0 +1,70 @@
+package heapescapeanalysis
+
+import (
+	"html/template"
+	"io"
+	"sort"
+)
+
+// escapeFileGroup is the notes of a single file, in line order.
+type escapeFileGroup struct {
+	File  string
+	Notes []EscapeNote
+}
+
+var escapeHTMLTemplate = template.Must(template.New("escapes").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Escape analysis report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+td.msg { font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>Escape analysis report</h1>
+<p>{{len .Notes}} notes in {{len .Groups}} files</p>
+{{range .Groups}}<h2>{{.File}}</h2>
+<table>
+<tr><th>Line</th><th>Message</th></tr>
+{{range .Notes}}<tr><td><a href="{{.File}}#L{{.Line}}">{{.Line}}:{{.Column}}</a></td><td class="msg">{{.Message}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// RenderEscapeHTML writes a self-contained HTML page listing notes grouped by
+// file, with each line linking to "<file>#L<line>". Files are sorted by name
+// and notes by position so the output is stable. All content is escaped by
+// html/template.
+func RenderEscapeHTML(w io.Writer, notes []EscapeNote) error {
+	sorted := make([]EscapeNote, len(notes))
+	copy(sorted, notes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	var groups []escapeFileGroup
+	for _, n := range sorted {
+		if len(groups) == 0 || groups[len(groups)-1].File != n.File {
+			groups = append(groups, escapeFileGroup{File: n.File})
+		}
+		g := &groups[len(groups)-1]
+		g.Notes = append(g.Notes, n)
+	}
+
+	return escapeHTMLTemplate.Execute(w, struct {
+		Notes  []EscapeNote
+		Groups []escapeFileGroup
+	}{sorted, groups})
+}
@@ -0,0 +1,44 @@
+package heapescapeanalysis
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestRenderEscapeHTML(t *testing.T) {
+	notes := []EscapeNote{
+		{File: "keep_on_stack.go", Line: 88, Column: 6, Message: "can inline returnToPool"},
+		{File: "functions.go", Line: 7, Column: 2, Message: "moved to heap: x"},
+		{File: "functions.go", Line: 41, Column: 9, Message: "x escapes to heap"},
+		{File: "functions.go", Line: 25, Column: 2, Message: "moved to heap: s"},
+		{File: "<script>.go", Line: 1, Column: 1, Message: `"quoted" & <b>bold</b>`},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderEscapeHTML(&buf, notes); err != nil {
+		t.Fatalf("RenderEscapeHTML: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "escape_report.golden.html")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want) {
+		t.Errorf("RenderEscapeHTML output differs from %s (run with -update to regenerate):\n%s", golden, got)
+	}
+	if strings.Contains(buf.String(), "<b>bold</b>") {
+		t.Error("RenderEscapeHTML did not escape note content")
+	}
+}
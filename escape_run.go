@@ -0,0 +1,31 @@
+package heapescapeanalysis
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// RunEscapeAnalysis compiles the package at pkgPath (an import path or a
+// relative directory such as ".") with -gcflags=-m=2 using the go command on
+// PATH, and returns the parsed escape-analysis notes. Build results are
+// cached by the go command, which replays the compiler output, so repeated
+// calls are cheap unless the sources change.
+func RunEscapeAnalysis(pkgPath string) ([]EscapeNote, error) {
+	return runEscapeAnalysisWith("go", pkgPath)
+}
+
+// runEscapeAnalysisWith is RunEscapeAnalysis with an explicit go binary.
+// Any env entries (KEY=value) are added to the current environment.
+func runEscapeAnalysisWith(goBin, pkgPath string, env ...string) ([]EscapeNote, error) {
+	cmd := exec.Command(goBin, "build", "-gcflags=-m=2", pkgPath)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s build %s: %v\n%s", goBin, pkgPath, err, out)
+	}
+	return ParseEscapeNotes(bytes.NewReader(out))
+}
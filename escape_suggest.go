@@ -0,0 +1,44 @@
+package heapescapeanalysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+var movedToHeapRE = regexp.MustCompile(`^moved to heap: (\S+)`)
+
+// SuggestPointerParams inspects the escape notes of function fn and returns
+// advisory suggestions for converting it to the pointer-parameter style of
+// setLargeStructViaPointer, where the caller owns the memory and the function
+// fills it in place. Each suggestion names the evidence it is based on.
+//
+// The heuristic only looks for signs of large or escaping values:
+//   - "moved to heap: v": v outlives fn, typically because &v is returned
+//   - "too large for stack": a value had to be heap allocated due to its size
+//
+// It returns nil when nothing in the notes suggests a change. Suggestions are
+// not guaranteed to be improvements; measure before and after.
+func SuggestPointerParams(notes []EscapeNote, fn string) []string {
+	var suggestions []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			suggestions = append(suggestions, s)
+		}
+	}
+
+	for _, n := range notesForFunc(notes, fn) {
+		if m := movedToHeapRE.FindStringSubmatch(n.Message); m != nil {
+			add(fn + ": " + m[1] + " is moved to heap; if its address is returned, " +
+				"accept a pointer parameter and initialize the caller's value in place " +
+				"(like setLargeStructViaPointer) so the caller decides where it lives")
+			continue
+		}
+		if strings.Contains(n.Message, "too large for stack") {
+			add(fn + ": a value is too large for the stack (" + n.Message + "); " +
+				"pass a pointer to caller-owned memory instead of creating and returning it")
+		}
+	}
+	return suggestions
+}
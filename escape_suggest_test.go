@@ -0,0 +1,63 @@
+package heapescapeanalysis
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSuggestPointerParams(t *testing.T) {
+	notes := []EscapeNote{
+		{File: "functions.go", Line: 25, Column: 6, Message: "cannot inline returnLargePointer: marked go:noinline"},
+		{File: "functions.go", Line: 26, Column: 2, Message: "moved to heap: s"},
+		{File: "functions.go", Line: 31, Column: 6, Message: "cannot inline returnLargeValue: marked go:noinline"},
+		{File: "functions.go", Line: 32, Column: 7, Message: "LargeStruct{} escapes to heap: too large for stack"},
+		{File: "functions.go", Line: 32, Column: 7, Message: "LargeStruct{} escapes to heap: too large for stack"},
+		{File: "functions.go", Line: 88, Column: 6, Message: "cannot inline setLargeStructViaPointer: marked go:noinline"},
+		{File: "functions.go", Line: 89, Column: 31, Message: "result does not escape"},
+		{File: "keep_on_stack.go", Line: 40, Column: 2, Message: "x escapes to heap in returnLargeValue:"},
+	}
+
+	tests := []struct {
+		fn       string
+		want     int
+		contains []string
+	}{
+		{"returnLargePointer", 1, []string{"returnLargePointer: s is moved to heap", "setLargeStructViaPointer"}},
+		{"returnLargeValue", 1, []string{"too large for stack", "pointer to caller-owned memory"}},
+		{"setLargeStructViaPointer", 0, nil},
+		{"unknownFunction", 0, nil},
+	}
+	for _, tt := range tests {
+		got := SuggestPointerParams(notes, tt.fn)
+		if len(got) != tt.want {
+			t.Errorf("SuggestPointerParams(%s) returned %d suggestions, want %d: %q", tt.fn, len(got), tt.want, got)
+			continue
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(got[0], s) {
+				t.Errorf("SuggestPointerParams(%s) = %q, want it to mention %q", tt.fn, got[0], s)
+			}
+		}
+	}
+}
+
+func TestNotesForFunc(t *testing.T) {
+	notes := []EscapeNote{
+		{File: "a.go", Line: 1, Message: "can inline first"},
+		{File: "a.go", Line: 2, Message: "moved to heap: x"},
+		{File: "a.go", Line: 3, Message: "can inline first.func1"},
+		{File: "a.go", Line: 4, Message: "func literal escapes to heap"},
+		{File: "a.go", Line: 10, Message: "cannot inline second: marked go:noinline"},
+		{File: "a.go", Line: 11, Message: "y escapes to heap in first:"},
+		{File: "b.go", Line: 2, Message: "moved to heap: z"},
+	}
+	got := notesForFunc(notes, "first")
+	var lines []int
+	for _, n := range got {
+		lines = append(lines, n.Line)
+	}
+	if want := []int{1, 2, 3, 4, 11}; !slices.Equal(lines, want) {
+		t.Errorf("notesForFunc(first) lines = %v, want %v", lines, want)
+	}
+}
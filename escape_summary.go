@@ -0,0 +1,95 @@
+package heapescapeanalysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// escapedValue returns what a note says was heap allocated: the variable of
+// "moved to heap: x" or the expression of "make([]int, n) escapes to heap".
+// Notes that are not such verdicts, including the -m=2 flow explanations
+// that repeat them, return false.
+func escapedValue(msg string) (string, bool) {
+	if v, ok := strings.CutPrefix(msg, "moved to heap: "); ok {
+		return v, true
+	}
+	if strings.HasPrefix(msg, " ") || strings.HasSuffix(msg, ":") {
+		return "", false
+	}
+	if i := strings.Index(msg, " escapes to heap"); i > 0 {
+		return msg[:i], true
+	}
+	return "", false
+}
+
+// isExportedFunc reports whether a compiler function name such as "Max",
+// "EscapeNote.String" or "(*Interner).Intern" is part of the exported API:
+// the function, and for methods the receiver type, must be exported.
+func isExportedFunc(name string) bool {
+	if closureNameRE.MatchString(name) {
+		return false
+	}
+	for _, part := range strings.Split(strings.NewReplacer("(", "", ")", "", "*", "").Replace(name), ".") {
+		r, _ := utf8.DecodeRuneInString(part)
+		if !unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// markdownCellEscaper keeps compiler expressions from breaking table cells
+// or code spans.
+var markdownCellEscaper = strings.NewReplacer("|", `\|`, "`", "'")
+
+// GenerateEscapeSummary runs RunEscapeAnalysis on pkgPath and returns a
+// markdown table with one row per exported function the compiler reported
+// on, saying whether anything in it is heap allocated and what. Rows are
+// sorted by function name so the output is stable for a given toolchain.
+func GenerateEscapeSummary(pkgPath string) (string, error) {
+	notes, err := RunEscapeAnalysis(pkgPath)
+	if err != nil {
+		return "", err
+	}
+
+	seen := make(map[string]bool)
+	var funcs []string
+	for _, n := range notes {
+		m := inlineDeclRE.FindStringSubmatch(n.Message)
+		if m != nil && isExportedFunc(m[1]) && !seen[m[1]] {
+			seen[m[1]] = true
+			funcs = append(funcs, m[1])
+		}
+	}
+	sort.Strings(funcs)
+
+	var sb strings.Builder
+	sb.WriteString("| Function | Verdict | Heap allocated |\n")
+	sb.WriteString("|----------|---------|----------------|\n")
+	for _, fn := range funcs {
+		verdict, allocated := escapeVerdictCells(notes, fn)
+		fmt.Fprintf(&sb, "| `%s` | %s | %s |\n", fn, verdict, allocated)
+	}
+	return sb.String(), nil
+}
+
+// escapeVerdictCells returns the Verdict ("heap" or "stack") and Heap
+// allocated cells of fn's row in an escape summary table.
+func escapeVerdictCells(notes []EscapeNote, fn string) (verdict, allocated string) {
+	var escaped []string
+	dup := make(map[string]bool)
+	for _, n := range notesForFunc(notes, fn) {
+		if v, ok := escapedValue(n.Message); ok && !dup[v] {
+			dup[v] = true
+			escaped = append(escaped, "`"+markdownCellEscaper.Replace(v)+"`")
+		}
+	}
+	verdict = "stack"
+	if len(escaped) > 0 {
+		verdict = "heap"
+	}
+	return verdict, strings.Join(escaped, ", ")
+}
@@ -0,0 +1,78 @@
+package heapescapeanalysis
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestEscapedValue(t *testing.T) {
+	tests := []struct {
+		msg, want string
+		ok        bool
+	}{
+		{"moved to heap: x", "x", true},
+		{"make([]int, size) escapes to heap", "make([]int, size)", true},
+		{"x escapes to heap in returnPointer:", "", false},
+		{"  flow: ~r0 ← &x:", "", false},
+		{"x does not escape", "", false},
+		{"leaking param: xs", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := escapedValue(tt.msg)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("escapedValue(%q) = %q, %v, want %q, %v", tt.msg, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsExportedFunc(t *testing.T) {
+	tests := map[string]bool{
+		"Max":                      true,
+		"EscapeNote.String":        true,
+		"(*Interner).Intern":       true,
+		"(*Interner).add":          false,
+		"(*statsPool).Get":         false,
+		"returnPointer":            false,
+		"RenderEscapeHTML.func1":   false,
+		"NewWorkerPool.deferwrap1": false,
+	}
+	for name, want := range tests {
+		if got := isExportedFunc(name); got != want {
+			t.Errorf("isExportedFunc(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestGenerateEscapeSummary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the compiler")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	summary, err := GenerateEscapeSummary(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range []string{
+		"| `NewBufferProcessor` | heap | `&BufferProcessor{...}`, `make([]byte, 0, 1024)` |",
+		"| `(*Interner).Len` | stack |  |",
+	} {
+		if !strings.Contains(summary, row) {
+			t.Errorf("summary is missing row %q:\n%s", row, summary)
+		}
+	}
+	if strings.Contains(summary, "returnPointer") {
+		t.Error("summary includes unexported function returnPointer")
+	}
+
+	again, err := GenerateEscapeSummary(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != summary {
+		t.Error("GenerateEscapeSummary output is not deterministic")
+	}
+}
@@ -0,0 +1,97 @@
+package heapescapeanalysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEscapeNoteString(t *testing.T) {
+	n := EscapeNote{File: "./functions.go", Line: 7, Column: 2, Message: "moved to heap: x"}
+	if got, want := n.String(), "./functions.go:7:2: moved to heap: x"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestParseEscapeNotes(t *testing.T) {
+	input := "# example.com/pkg\n" +
+		"./a.go:7:2: moved to heap: x\r\n" +
+		"\n" +
+		"C:\\src\\b.go:3:14: leaking param: p\n" +
+		"./a.go:9:9: inlining call to f\n" +
+		"  with extra detail\n"
+	notes, err := ParseEscapeNotes(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EscapeNote{
+		{File: "./a.go", Line: 7, Column: 2, Message: "moved to heap: x"},
+		{File: `C:\src\b.go`, Line: 3, Column: 14, Message: "leaking param: p"},
+		{File: "./a.go", Line: 9, Column: 9, Message: "inlining call to f\n  with extra detail"},
+	}
+	if len(notes) != len(want) {
+		t.Fatalf("parsed %d notes, want %d: %+v", len(notes), len(want), notes)
+	}
+	for i := range want {
+		if notes[i] != want[i] {
+			t.Errorf("note %d = %+v, want %+v", i, notes[i], want[i])
+		}
+	}
+}
+
+// normalizeEscapeOutput drops package headers and trailing whitespace.
+func normalizeEscapeOutput(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestFormatNotesRoundTrip(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "escape_m2.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes, err := ParseEscapeNotes(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) == 0 {
+		t.Fatal("fixture parsed to no notes")
+	}
+
+	got := normalizeEscapeOutput(FormatNotes(notes))
+	if want := normalizeEscapeOutput(string(raw)); got != want {
+		t.Errorf("FormatNotes(ParseEscapeNotes(fixture)) differs from fixture:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	again, err := ParseEscapeNotes(strings.NewReader(FormatNotes(notes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range notes {
+		if again[i] != notes[i] {
+			t.Errorf("note %d changed on second round trip: %+v, want %+v", i, again[i], notes[i])
+		}
+	}
+}
+
+func TestFormatNotesMultiLine(t *testing.T) {
+	notes := []EscapeNote{
+		{File: "a.go", Line: 1, Column: 1, Message: "first\n  detail"},
+		{File: "a.go", Line: 2, Column: 1, Message: "second"},
+	}
+	parsed, err := ParseEscapeNotes(strings.NewReader(FormatNotes(notes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 || parsed[0] != notes[0] || parsed[1] != notes[1] {
+		t.Errorf("round trip = %+v, want %+v", parsed, notes)
+	}
+}
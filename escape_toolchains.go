@@ -0,0 +1,61 @@
+package heapescapeanalysis
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// CompareAcrossToolchains runs escape analysis on pkgPath once per go binary
+// in goBinaries and returns the notes keyed by the version each binary
+// reports (for example "go1.24.2"). Diffing two entries shows which escape
+// decisions a compiler upgrade changes.
+//
+// A binary that is missing or fails to build the package does not stop the
+// comparison: its error is collected, the remaining binaries still run, and
+// the returned error joins every per-binary failure. Binaries reporting the
+// same version are analyzed once.
+//
+// Each binary is run with GOTOOLCHAIN=local so it does not switch to the
+// toolchain named in go.mod. Install extra versions side by side with
+//
+//	go install golang.org/dl/go1.24.2@latest
+//	go1.24.2 download
+//
+// and pass their names or paths, e.g. []string{"go", "go1.24.2"}. A
+// toolchain older than the module's go directive reports an error instead of
+// results.
+func CompareAcrossToolchains(pkgPath string, goBinaries []string) (map[string][]EscapeNote, error) {
+	results := make(map[string][]EscapeNote, len(goBinaries))
+	var errs []error
+	for _, goBin := range goBinaries {
+		version, err := toolchainVersion(goBin)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, ok := results[version]; ok {
+			continue
+		}
+		notes, err := runEscapeAnalysisWith(goBin, pkgPath, "GOTOOLCHAIN=local")
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		results[version] = notes
+	}
+	return results, errors.Join(errs...)
+}
+
+// toolchainVersion returns the version reported by goBin, such as "go1.24.2".
+func toolchainVersion(goBin string) (string, error) {
+	cmd := exec.Command(goBin, "env", "GOVERSION")
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s env GOVERSION: %v", goBin, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,50 @@
+package heapescapeanalysis
+
+import (
+	"maps"
+	"os/exec"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestCompareAcrossToolchains(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the compiler")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	want, err := toolchainVersion("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := CompareAcrossToolchains(".", []string{"go", "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got results for %d versions, want 1: %v", len(results), slices.Sorted(maps.Keys(results)))
+	}
+	notes, ok := results[want]
+	if !ok {
+		t.Fatalf("no results for %s (running under %s): %v", want, runtime.Version(), slices.Sorted(maps.Keys(results)))
+	}
+	if len(notesForFunc(notes, "returnPointer")) == 0 {
+		t.Error("no notes reported for returnPointer")
+	}
+}
+
+func TestCompareAcrossToolchainsMissingBinary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the compiler")
+	}
+	results, err := CompareAcrossToolchains(".", []string{"go-toolchain-that-does-not-exist", "go"})
+	if err == nil {
+		t.Fatal("CompareAcrossToolchains with a missing binary returned nil error")
+	}
+	if _, verr := toolchainVersion("go"); verr == nil && len(results) != 1 {
+		t.Errorf("missing binary dropped the other results: got %d versions, want 1", len(results))
+	}
+}
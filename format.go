@@ -0,0 +1,119 @@
+package heapescapeanalysis
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Formatting into caller-provided buffers
+//
+// fmt.Sprintf returns a new string every call, and its ...interface{}
+// arguments make values escape into boxes. The helpers below append to a
+// []byte the caller owns instead, in the style of strconv.AppendInt. When
+// the buffer has enough capacity they do not allocate at all, and a buffer
+// reused across calls quickly reaches that point. They grow dst with append
+// when it is too small.
+
+// AppendInt appends the decimal form of v to dst.
+func AppendInt(dst []byte, v int) []byte {
+	return strconv.AppendInt(dst, int64(v), 10)
+}
+
+// AppendString appends s to dst.
+func AppendString(dst []byte, s string) []byte {
+	return append(dst, s...)
+}
+
+// Appendf appends the formatted arguments to dst. It supports a subset of
+// fmt verbs:
+//
+//	%d  int
+//	%s  string or []byte
+//	%v  int, string or []byte
+//	%%  a literal percent sign
+//
+// Flags, widths and other verbs are not supported. Problems are reported
+// inline like fmt does: "%!d(MISSING)" for a missing argument,
+// "%!d(BADTYPE)" for an argument of an unsupported type, "%!x(BADVERB)" for
+// an unknown verb and "%!(EXTRA)" when arguments are left over.
+//
+// The arguments do not escape, so callers' boxes stay on the stack and
+// Appendf does not allocate when dst has room for the result.
+func Appendf(dst []byte, format string, args ...interface{}) []byte {
+	argi := 0
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			dst = append(dst, c)
+			continue
+		}
+		i++
+		verb := format[i]
+		if verb == '%' {
+			dst = append(dst, '%')
+			continue
+		}
+		if verb != 'd' && verb != 's' && verb != 'v' {
+			dst = append(dst, '%', '!', verb)
+			dst = append(dst, "(BADVERB)"...)
+			continue
+		}
+		if argi >= len(args) {
+			dst = append(dst, '%', '!', verb)
+			dst = append(dst, "(MISSING)"...)
+			continue
+		}
+		arg := args[argi]
+		argi++
+
+		switch v := arg.(type) {
+		case int:
+			if verb != 's' {
+				dst = AppendInt(dst, v)
+				continue
+			}
+		case string:
+			if verb != 'd' {
+				dst = AppendString(dst, v)
+				continue
+			}
+		case []byte:
+			if verb != 'd' {
+				dst = append(dst, v...)
+				continue
+			}
+		}
+		dst = append(dst, '%', '!', verb)
+		dst = append(dst, "(BADTYPE)"...)
+	}
+	if argi < len(args) {
+		dst = append(dst, "%!(EXTRA)"...)
+	}
+	return dst
+}
+
+// fmt.Sprint versus strconv
+//
+// fmt.Sprint has no format string, but it is the same machinery as Sprintf:
+// its ...interface{} parameter boxes every argument at the call site, and
+// because the values flow into fmt's printer the boxes escape, one heap
+// allocation per non-constant argument outside the runtime's small-int
+// cache, plus the result string. Each value is then formatted through a type
+// switch on the interface. Converting with strconv.AppendInt into a stack
+// buffer boxes nothing, and the only allocation left is the string returned
+// to the caller. Note that Sprint puts a space between operands only when
+// neither is a string, so strconvConcat adds it explicitly to match.
+
+//go:noinline
+func sprintConcat(a, b int) string {
+	return fmt.Sprint(a, b) // Boxes a and b, then allocates the result
+}
+
+//go:noinline
+func strconvConcat(a, b int) string {
+	var buf [41]byte // Two int64s and a space, on the stack
+	out := strconv.AppendInt(buf[:0], int64(a), 10)
+	out = append(out, ' ')
+	out = strconv.AppendInt(out, int64(b), 10)
+	return string(out) // The only allocation
+}
@@ -0,0 +1,132 @@
+package heapescapeanalysis
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func BenchmarkComparison_Appendf(b *testing.B) {
+	user, id := "alice", 12345
+
+	b.Run("Appendf", func(b *testing.B) {
+		buf := make([]byte, 0, 64)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf = Appendf(buf[:0], "user=%s id=%d", user, id+i)
+		}
+		result = buf
+	})
+
+	b.Run("Sprintf", func(b *testing.B) {
+		var r string
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = fmt.Sprintf("user=%s id=%d", user, id+i)
+		}
+		result = r
+	})
+}
+
+func TestAppendf(t *testing.T) {
+	tests := []struct {
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"plain", nil, "plain"},
+		{"%d", []interface{}{-42}, "-42"},
+		{"%s", []interface{}{"str"}, "str"},
+		{"%s", []interface{}{[]byte("bytes")}, "bytes"},
+		{"%v %v %v", []interface{}{7, "seven", []byte("7")}, "7 seven 7"},
+		{"100%%", nil, "100%"},
+		{"trailing %", nil, "trailing %"},
+		{"%d %d", []interface{}{1}, "1 %!d(MISSING)"},
+		{"%d", []interface{}{"x"}, "%!d(BADTYPE)"},
+		{"%s", []interface{}{3}, "%!s(BADTYPE)"},
+		{"%v", []interface{}{1.5}, "%!v(BADTYPE)"},
+		{"%x", []interface{}{1}, "%!x(BADVERB)%!(EXTRA)"},
+		{"%d", []interface{}{1, 2}, "1%!(EXTRA)"},
+	}
+	for _, tt := range tests {
+		if got := string(Appendf(nil, tt.format, tt.args...)); got != tt.want {
+			t.Errorf("Appendf(%q, %v) = %q, want %q", tt.format, tt.args, got, tt.want)
+		}
+	}
+
+	// Supported verbs agree with fmt
+	if got, want := string(Appendf(nil, "%s=%d (%v)", "n", 12, "ok")), fmt.Sprintf("%s=%d (%v)", "n", 12, "ok"); got != want {
+		t.Errorf("Appendf = %q, fmt.Sprintf = %q", got, want)
+	}
+}
+
+func TestAppendHelpers(t *testing.T) {
+	buf := AppendString([]byte("x="), "1")
+	buf = AppendInt(buf, -23)
+	if string(buf) != "x=1-23" {
+		t.Errorf("AppendString/AppendInt = %q, want %q", buf, "x=1-23")
+	}
+}
+
+func TestAppendfBufferGrowth(t *testing.T) {
+	small := make([]byte, 0, 4)
+	out := Appendf(append(small, "ab"...), "%s-%d", "longer than four", 123456)
+	if string(out) != "ablonger than four-123456" {
+		t.Errorf("Appendf = %q", out)
+	}
+	if cap(out) <= cap(small) {
+		t.Errorf("cap = %d, want dst to have grown past %d", cap(out), cap(small))
+	}
+
+	buf := make([]byte, 0, 64)
+	user, id := "alice", 12345
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = Appendf(buf[:0], "user=%s id=%d", user, id)
+	})
+	if allocs != 0 {
+		t.Errorf("Appendf with spare capacity allocs = %v, want 0", allocs)
+	}
+	if string(buf) != "user=alice id=12345" {
+		t.Errorf("Appendf = %q", buf)
+	}
+}
+
+func BenchmarkComparison_SprintConcat(b *testing.B) {
+	b.Run("Sprint", func(b *testing.B) {
+		var r string
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = sprintConcat(1000+i, 2000+i)
+		}
+		result = r
+	})
+
+	b.Run("Strconv", func(b *testing.B) {
+		var r string
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = strconvConcat(1000+i, 2000+i)
+		}
+		result = r
+	})
+}
+
+func TestSprintConcat(t *testing.T) {
+	for _, tt := range [][2]int{{0, 0}, {1, -2}, {12345, 678}, {math.MinInt64, math.MaxInt64}} {
+		got, want := strconvConcat(tt[0], tt[1]), sprintConcat(tt[0], tt[1])
+		if got != want {
+			t.Errorf("strconvConcat(%d, %d) = %q, sprintConcat = %q", tt[0], tt[1], got, want)
+		}
+	}
+
+	var r string
+	a := 1000
+	allocs := testing.AllocsPerRun(100, func() {
+		a++
+		r = strconvConcat(a, a+1)
+	})
+	result = r
+	if allocs != 1 {
+		t.Errorf("strconvConcat allocs = %v, want 1 (the result string)", allocs)
+	}
+}
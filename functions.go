@@ -29,7 +29,7 @@ func returnLargePointer() *LargeStruct {
 
 //go:noinline
 func returnLargeValue() LargeStruct {
-	s := LargeStruct{} // May escape due to size
+	s := LargeStruct{} // 24KB: under the stack limit, see returnJustOverLimit
 	return s
 }
 
@@ -0,0 +1,140 @@
+package heapescapeanalysis
+
+import "cmp"
+
+// Generics versus interface{} for containers and algorithms
+//
+// An interface{}-based algorithm needs its inputs boxed: every int stored in
+// a []interface{} is copied to the heap (except small values the runtime
+// caches), and each comparison needs a type switch. A generic function is
+// compiled for the type arguments it is used with. Go shares one copy of the
+// code between types with the same GC shape and passes a dictionary for the
+// type-specific bits, but int has its own shape, so Max[int] compares plain
+// ints and nothing is boxed.
+
+// Max returns the largest element of xs, or the zero value if xs is empty.
+func Max[T cmp.Ordered](xs []T) T {
+	var m T
+	for i, x := range xs {
+		if i == 0 || x > m {
+			m = x
+		}
+	}
+	return m
+}
+
+//go:noinline
+func maxInterface(xs []interface{}) interface{} {
+	var m interface{}
+	for _, x := range xs {
+		switch v := x.(type) {
+		case int:
+			if m == nil || v > m.(int) {
+				m = v
+			}
+		}
+	}
+	return m
+}
+
+// Returning a pointer from a generic function
+//
+// Type parameters do not change escape analysis. newOf is analyzed once per
+// GC shape, and in every instantiation the returned address outlives the
+// call, so new(T) is moved to the heap whether T is an 8-byte int or a 24KB
+// LargeStruct. As with any function, a caller can only avoid the allocation
+// if newOf is inlined and the caller's use of the pointer stays local.
+
+//go:noinline
+func newOf[T any]() *T {
+	return new(T) // Escapes: returned to the caller
+}
+
+// Tree walking: interface visitors versus a generic callback
+//
+// The classic visitor pattern describes nodes with an interface so one walker
+// can traverse any tree. When nodes are stored by value, as they usually are
+// in compact ASTs, every Child call has to convert a node into a Node, and
+// that conversion copies it into a new heap box: one allocation per node
+// visited, on every walk. Each Visit and Child call is also dynamic dispatch
+// the compiler cannot inline. visitGeneric walks the same tree through
+// pointers into the nodes themselves, so nothing is copied or boxed; the
+// callbacks are still indirect calls, but the walk allocates nothing.
+
+// Node is a tree node as seen by an interface-based walker.
+type Node interface {
+	Value() int
+	NumChildren() int
+	Child(i int) Node
+}
+
+// Visitor is called once per node by visitInterface.
+type Visitor interface {
+	Visit(n Node)
+}
+
+// treeNode is a tree node whose children are stored inline by value.
+type treeNode struct {
+	val  int
+	kids []treeNode
+}
+
+func (t treeNode) Value() int       { return t.val }
+func (t treeNode) NumChildren() int { return len(t.kids) }
+func (t treeNode) Child(i int) Node { return t.kids[i] } // Boxed: 32 bytes per call
+
+// visitInterface calls v.Visit on n and then on its descendants, depth
+// first.
+//
+//go:noinline
+func visitInterface(n Node, v Visitor) {
+	v.Visit(n)
+	for i := 0; i < n.NumChildren(); i++ {
+		visitInterface(n.Child(i), v)
+	}
+}
+
+// visitGeneric calls visit on n and then on its descendants, depth first,
+// using children to find each node's children in place.
+//
+//go:noinline
+func visitGeneric[V any](n *V, children func(*V) []V, visit func(*V)) {
+	visit(n)
+	kids := children(n)
+	for i := range kids {
+		visitGeneric(&kids[i], children, visit) // Pointer into the tree, no copy
+	}
+}
+
+// Interface type arguments
+//
+// A type parameter does not box by itself: Identity[int] is compiled for
+// int's GC shape and moves a plain int in and out. Boxing depends only on
+// the type argument. With T = interface{}, written explicitly or inferred
+// from an interface-typed argument, the conversion from int to interface{}
+// happens at the call site before Identity ever runs, exactly as for a
+// non-generic func(interface{}) interface{}, and the box escapes as soon as
+// the result is stored somewhere long-lived. Type inference picks T from the
+// static type of the argument, so the same call Identity(v) boxes or not
+// depending on whether v was declared as an interface or as an int.
+
+// Identity returns v.
+func Identity[T any](v T) T {
+	return v
+}
+
+// identitySink and identityIntSink keep Identity's results reachable.
+var (
+	identitySink    interface{}
+	identityIntSink int
+)
+
+//go:noinline
+func identityAsInterface(n int) {
+	identitySink = Identity[interface{}](n) // n boxed at the call site
+}
+
+//go:noinline
+func identityConcrete(n int) {
+	identityIntSink = Identity(n) // T inferred as int, no box
+}
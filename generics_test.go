@@ -0,0 +1,238 @@
+package heapescapeanalysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func largeInts(n int) []int {
+	xs := make([]int, n)
+	for i := range xs {
+		xs[i] = 1000 + (i*37)%n // Above the runtime's cache of small boxed ints
+	}
+	return xs
+}
+
+func BenchmarkComparison_GenericVsInterfaceMax(b *testing.B) {
+	xs := largeInts(100)
+
+	b.Run("Generic", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = Max(xs)
+		}
+		result = r
+	})
+
+	b.Run("Interface", func(b *testing.B) {
+		var r interface{}
+		boxed := make([]interface{}, len(xs))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j, x := range xs {
+				boxed[j] = x // Boxing is part of the interface API's cost
+			}
+			r = maxInterface(boxed)
+		}
+		result = r
+	})
+}
+
+func TestMax(t *testing.T) {
+	xs := largeInts(100)
+	boxed := make([]interface{}, len(xs))
+	for i, x := range xs {
+		boxed[i] = x
+	}
+	if got, want := Max(xs), maxInterface(boxed).(int); got != want {
+		t.Errorf("Max = %d, maxInterface = %d", got, want)
+	}
+	if got := Max([]string{"b", "c", "a"}); got != "c" {
+		t.Errorf("Max(strings) = %q, want c", got)
+	}
+	if got := Max([]float64{-3, -1, -2}); got != -1 {
+		t.Errorf("Max(negative floats) = %v, want -1", got)
+	}
+	if got := Max[int](nil); got != 0 {
+		t.Errorf("Max(nil) = %d, want 0", got)
+	}
+
+	var r int
+	allocs := testing.AllocsPerRun(100, func() {
+		r = Max(xs)
+	})
+	result = r
+	if allocs != 0 {
+		t.Errorf("Max allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkNewOf(b *testing.B) {
+	b.Run("Int", func(b *testing.B) {
+		var r *int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = newOf[int]()
+		}
+		result = r
+	})
+
+	b.Run("LargeStruct", func(b *testing.B) {
+		var r *LargeStruct
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = newOf[LargeStruct]()
+		}
+		result = r
+	})
+}
+
+func TestNewOfAllocs(t *testing.T) {
+	if p := newOf[int](); p == nil || *p != 0 {
+		t.Fatalf("newOf[int]() = %v, want pointer to 0", p)
+	}
+
+	var small *int
+	smallAllocs := testing.AllocsPerRun(100, func() { small = newOf[int]() })
+	var large *LargeStruct
+	largeAllocs := testing.AllocsPerRun(100, func() { large = newOf[LargeStruct]() })
+	result = small
+	result = large
+	if smallAllocs != 1 {
+		t.Errorf("newOf[int] allocs = %v, want 1", smallAllocs)
+	}
+	if largeAllocs != 1 {
+		t.Errorf("newOf[LargeStruct] allocs = %v, want 1", largeAllocs)
+	}
+}
+
+// buildTree returns a complete tree of the given depth and fanout whose nodes
+// are numbered in depth-first order.
+func buildTree(depth, fanout int) treeNode {
+	next := 0
+	var build func(d int) treeNode
+	build = func(d int) treeNode {
+		n := treeNode{val: next}
+		next++
+		if d > 1 {
+			n.kids = make([]treeNode, fanout)
+			for i := range n.kids {
+				n.kids[i] = build(d - 1)
+			}
+		}
+		return n
+	}
+	return build(depth)
+}
+
+// orderVisitor records the values of the nodes it visits.
+type orderVisitor struct {
+	order []int
+}
+
+func (v *orderVisitor) Visit(n Node) {
+	v.order = append(v.order, n.Value())
+}
+
+func treeKids(n *treeNode) []treeNode { return n.kids }
+
+func BenchmarkComparison_TreeVisitor(b *testing.B) {
+	tree := buildTree(5, 3) // 121 nodes
+
+	b.Run("Interface", func(b *testing.B) {
+		v := &orderVisitor{order: make([]int, 0, 128)}
+		var root Node = tree
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v.order = v.order[:0]
+			visitInterface(root, v)
+		}
+		result = v.order
+	})
+
+	b.Run("Generic", func(b *testing.B) {
+		order := make([]int, 0, 128)
+		visit := func(n *treeNode) { order = append(order, n.val) }
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			order = order[:0]
+			visitGeneric(&tree, treeKids, visit)
+		}
+		result = order
+	})
+}
+
+func TestVisitorsVisitInSameOrder(t *testing.T) {
+	tree := buildTree(4, 3)
+
+	v := &orderVisitor{}
+	visitInterface(tree, v)
+	var order []int
+	visitGeneric(&tree, treeKids, func(n *treeNode) { order = append(order, n.val) })
+
+	if len(v.order) != 40 {
+		t.Fatalf("visitInterface visited %d nodes, want 40", len(v.order))
+	}
+	if !slices.Equal(v.order, order) {
+		t.Errorf("visit orders differ:\ninterface: %v\ngeneric:   %v", v.order, order)
+	}
+	for i, val := range order {
+		if val != i {
+			t.Fatalf("visit %d saw node %d, want depth-first order", i, val)
+		}
+	}
+
+	v.order = make([]int, 0, 64)
+	var root Node = tree
+	ifaceAllocs := testing.AllocsPerRun(20, func() {
+		v.order = v.order[:0]
+		visitInterface(root, v)
+	})
+	order = make([]int, 0, 64)
+	visit := func(n *treeNode) { order = append(order, n.val) }
+	genericAllocs := testing.AllocsPerRun(20, func() {
+		order = order[:0]
+		visitGeneric(&tree, treeKids, visit)
+	})
+	if ifaceAllocs != 39 {
+		t.Errorf("visitInterface allocs = %v, want 39 (one box per child)", ifaceAllocs)
+	}
+	if genericAllocs != 0 {
+		t.Errorf("visitGeneric allocs = %v, want 0", genericAllocs)
+	}
+}
+
+func BenchmarkComparison_IdentityTypeArgument(b *testing.B) {
+	b.Run("Interface", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			identityAsInterface(1000 + i)
+		}
+	})
+
+	b.Run("Concrete", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			identityConcrete(1000 + i)
+		}
+	})
+}
+
+func TestIdentity(t *testing.T) {
+	identityAsInterface(1234)
+	identityConcrete(1234)
+	if identitySink.(int) != 1234 || identityIntSink != 1234 {
+		t.Errorf("Identity results = %v and %d, want 1234", identitySink, identityIntSink)
+	}
+
+	n := 1000
+	concrete := testing.AllocsPerRun(100, func() { n++; identityConcrete(n) })
+	boxed := testing.AllocsPerRun(100, func() { n++; identityAsInterface(n) })
+	if concrete != 0 {
+		t.Errorf("identityConcrete allocs = %v, want 0", concrete)
+	}
+	if boxed != 1 {
+		t.Errorf("identityAsInterface allocs = %v, want 1 (the box for n)", boxed)
+	}
+}
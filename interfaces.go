@@ -0,0 +1,359 @@
+package heapescapeanalysis
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Boxing a slice into interface{}
+//
+// An interface value is two words: a type pointer and a data pointer. A slice
+// header (pointer, length, capacity) is three words, so it does not fit in
+// the data word and must be copied into a separate 24-byte heap box. This
+// happens even though the elements already live elsewhere: the box holds the
+// header, not the backing array.
+
+//go:noinline
+func boxSlice(xs []int) interface{} {
+	return xs // Allocates a box for the slice header
+}
+
+//go:noinline
+func typedSlice(xs []int) []int {
+	return xs // Header returned in registers, no allocation
+}
+
+// Comparing through interface{}
+//
+// Generic containers written against interface{} compare elements with ==
+// on interface values. Each operand is boxed at the call site, and the
+// comparison itself must check the dynamic types and then dispatch to the
+// type's equality function at run time. Comparing concrete ints is a single
+// machine instruction.
+//
+// Because compareInterfaces does not let a or b escape, the compiler can keep
+// the boxes on the caller's stack, so the price here is time rather than
+// allocations. Values stored in an interface{}-based container are boxed on
+// the heap when inserted.
+
+//go:noinline
+func compareInterfaces(a, b interface{}) bool {
+	return a == b // Type check plus runtime equality call
+}
+
+//go:noinline
+func compareInts(a, b int) bool {
+	return a == b
+}
+
+// Returning an interface from a factory
+//
+// A constructor declared to return fmt.Stringer has to box whatever concrete
+// value it builds. The value does not fit in the interface's data word, so
+// it is copied into a heap box and the interface points at it, one
+// allocation per call. (Constant values are the exception: the compiler
+// boxes those once in read-only data.) Returning the concrete type lets the
+// caller keep the value on its stack; it can still be used as a fmt.Stringer
+// where needed.
+
+// point is a small value type that implements fmt.Stringer.
+type point struct {
+	X, Y int
+}
+
+func (p point) String() string {
+	return "(" + strconv.Itoa(p.X) + ", " + strconv.Itoa(p.Y) + ")"
+}
+
+//go:noinline
+func asStringer(x, y int) fmt.Stringer {
+	p := point{X: x, Y: y}
+	return p // Boxed: 16 bytes copied to the heap
+}
+
+//go:noinline
+func asPoint(x, y int) point {
+	return point{X: x, Y: y} // Returned in registers
+}
+
+// Pointer-returning methods behind an interface
+//
+// A method that returns the address of a local moves that local to the heap.
+// When the method is called on a concrete type the compiler can inline it
+// and, if the caller only reads through the pointer, keep the value on the
+// caller's stack after all. Through an interface it cannot: the call target
+// is unknown until run time, so nothing is inlined and the callee's
+// allocation happens on every call. A method returning the value instead
+// costs no allocation however it is called.
+
+// reading is a small measurement result.
+type reading struct {
+	Value int
+	Scale int
+}
+
+// pointerSensor returns readings by pointer.
+type pointerSensor interface {
+	ReadPtr() *reading
+}
+
+// valueSensor returns readings by value.
+type valueSensor interface {
+	Read() reading
+}
+
+type thermometer struct {
+	offset int
+}
+
+func (t thermometer) ReadPtr() *reading {
+	r := reading{Value: 20 + t.offset, Scale: 10} // Moved to heap
+	return &r
+}
+
+func (t thermometer) Read() reading {
+	return reading{Value: 20 + t.offset, Scale: 10}
+}
+
+// Package-level interface values keep the compiler from devirtualizing calls.
+var (
+	sensorByPointer pointerSensor = thermometer{offset: 1}
+	sensorByValue   valueSensor   = thermometer{offset: 1}
+)
+
+// Boxing a large value
+//
+// Boxing copies the value into heap memory owned by the interface. For an
+// int that is 8 bytes; for LargeStruct it is the whole 24,000-byte array,
+// allocated and copied on every conversion. Returning LargeStruct by value
+// copies it into the caller's result slot instead, which costs the same copy
+// but no allocation and no extra garbage for the collector.
+
+//go:noinline
+func boxLargeValue(n int) interface{} {
+	var s LargeStruct
+	s.data[0] = n
+	return s // Copies 24,000 bytes into a new heap box
+}
+
+//go:noinline
+func largeValue(n int) LargeStruct {
+	var s LargeStruct
+	s.data[0] = n
+	return s // Copied to the caller, no allocation
+}
+
+// Embedding interfaces versus concrete types in structs
+//
+// Middleware is often written as a struct that embeds the next handler as an
+// interface, so any implementation can be plugged in. Storing a value in
+// that field boxes it: the value is copied to the heap and the field holds a
+// pointer to it, even when the middleware struct itself is returned by value.
+// Embedding the concrete type stores the value inline, with no allocation,
+// at the cost of fixing the implementation at compile time.
+
+// requestHandler handles a request identified by an int.
+type requestHandler interface {
+	Handle(req int) int
+}
+
+// scaler is a requestHandler implementation.
+type scaler struct {
+	factor, offset int
+}
+
+func (s scaler) Handle(req int) int {
+	return req*s.factor + s.offset
+}
+
+// countingMiddleware wraps any requestHandler.
+type countingMiddleware struct {
+	requestHandler // Holds a pointer to a heap copy of the handler
+	calls          int
+}
+
+// countingScaler wraps a scaler directly.
+type countingScaler struct {
+	scaler // Stored inline
+	calls  int
+}
+
+func (m *countingMiddleware) serve(req int) int {
+	m.calls++
+	return m.Handle(req) // Dynamic dispatch through the interface
+}
+
+func (m *countingScaler) serve(req int) int {
+	m.calls++
+	return m.Handle(req)
+}
+
+//go:noinline
+func newCountingMiddleware(factor, offset int) countingMiddleware {
+	h := scaler{factor: factor, offset: offset}
+	return countingMiddleware{requestHandler: h} // h is boxed onto the heap
+}
+
+//go:noinline
+func newCountingScaler(factor, offset int) countingScaler {
+	h := scaler{factor: factor, offset: offset}
+	return countingScaler{scaler: h} // Copied into the struct
+}
+
+// Variadic interface{} parameters in a logging wrapper
+//
+// A printf-style logger takes args ...interface{}, so every argument is
+// converted to an interface at the call site. Because the values flow into
+// fmt, the compiler cannot prove they stay put, and each non-constant,
+// non-tiny value is boxed on the heap on every call, whether or not the
+// message is ever written. The implicit []interface{} holding them only
+// leaks its contents into fmt, so the slice itself stays on the caller's
+// stack; a logger that retained args would allocate it too. In logging-heavy
+// hot paths that boxing is usually the largest allocation source. A typed
+// entry point such as logInt takes the int directly and formats it with
+// strconv, so nothing is boxed.
+
+// logBuf holds the last formatted log line.
+var logBuf []byte
+
+//go:noinline
+func logf(format string, args ...interface{}) {
+	logBuf = fmt.Appendf(logBuf[:0], format, args...) // Each arg boxed by the caller
+}
+
+//go:noinline
+func logInt(msg string, code int) {
+	logBuf = append(logBuf[:0], msg...)
+	logBuf = append(logBuf, ' ')
+	logBuf = strconv.AppendInt(logBuf, int64(code), 10) // No boxing
+}
+
+// nil checks through an interface versus on the concrete pointer
+//
+// An interface value is a (type, value) pair, and it is nil only when both
+// halves are. Storing a nil *int in an interface{} sets the type half to
+// *int, so the interface compares != nil even though the pointer inside is
+// nil: the classic "typed nil" bug, typically seen when a function returns a
+// nil *MyError as error. The check should be made on the concrete pointer,
+// or the function should return a literal nil interface. Converting a
+// pointer to an interface does not allocate, since the pointer fits in the
+// value half directly, so this pitfall is about correctness, not cost.
+
+//go:noinline
+func checkInterfaceNil(v interface{}) bool {
+	return v == nil // False for a typed nil: the type half is set
+}
+
+//go:noinline
+func checkPointerNil(p *int) bool {
+	return p == nil
+}
+
+// Struct literals passed to variadic interface{} parameters
+//
+// Each struct passed as ...interface{} is boxed, and unlike small integers a
+// struct has no preallocated copy in the runtime, so every argument becomes
+// its own heap copy once the callee lets its arguments escape. When the
+// callee also keeps the slice itself, as a recorder that stores its
+// arguments for later does, the implicit []interface{} escapes too: three
+// points cost four allocations per call. A typed ...point parameter passes
+// the structs by value in a stack slice, and copying them into a reused
+// buffer allocates nothing.
+
+var (
+	recordedValues []interface{}
+	recordedPoints []point
+)
+
+//go:noinline
+func passStructsVariadic(vals ...interface{}) {
+	recordedValues = vals // Retains the slice and every boxed struct
+}
+
+//go:noinline
+func passStructsTyped(vals ...point) {
+	recordedPoints = append(recordedPoints[:0], vals...) // Copied by value
+}
+
+// Factory registries returning interfaces
+//
+// Plugin and factory registries map a name to a constructor returning an
+// interface. The map and its funcs are built once at init, and funcs that
+// capture nothing are static, so the registry itself costs nothing per call.
+// The cost is at the return: each constructor converts its concrete value to
+// Shape, and because the call goes through a func value the compiler cannot
+// inline it or see what the caller does with the result, so the value is
+// boxed on the heap every time. A switch that constructs the concrete type
+// directly lets the compiler call its methods statically and keep the value
+// on the stack. Registries stay the right tool when the set of kinds is open;
+// a switch wins when it is fixed and the path is hot.
+
+// Shape is implemented by the shapes a factory can build.
+type Shape interface {
+	Area() float64
+}
+
+type circle struct {
+	r float64
+}
+
+func (c circle) Area() float64 { return math.Pi * c.r * c.r }
+
+type rect struct {
+	w, h float64
+}
+
+func (r rect) Area() float64 { return r.w * r.h }
+
+var shapeFactories = map[string]func(size float64) Shape{
+	"circle": func(size float64) Shape { return circle{r: size} },
+	"square": func(size float64) Shape { return rect{w: size, h: size} }, // Boxed: 16 bytes
+}
+
+// factoryFromMap builds a shape of the given kind and size, or returns nil
+// for an unknown kind.
+//
+//go:noinline
+func factoryFromMap(kind string, size float64) Shape {
+	newShape, ok := shapeFactories[kind]
+	if !ok {
+		return nil
+	}
+	return newShape(size) // Heap-boxed by the constructor
+}
+
+// areaFromSwitch returns the area of a shape of the given kind and size, or
+// 0 for an unknown kind.
+//
+//go:noinline
+func areaFromSwitch(kind string, size float64) float64 {
+	switch kind {
+	case "circle":
+		return circle{r: size}.Area() // Concrete: no interface, no boxing
+	case "square":
+		return rect{w: size, h: size}.Area()
+	}
+	return 0
+}
+
+// Asserting a boxed value back out: by value versus by pointer
+//
+// v.(LargeStruct) cannot hand out the boxed memory itself, because the box
+// is immutable and shared by every copy of the interface; it copies all
+// 24,000 bytes into the result instead. The copy lands in the caller's
+// frame, so it does not allocate, but it is paid on every assertion. When
+// the interface holds a *LargeStruct, v.(*LargeStruct) copies one pointer,
+// and the caller reads the original fields through it. Boxing the pointer
+// is also free where boxing the value allocated (see boxLargeValue). The
+// price is sharing: writes through the pointer are visible to every holder.
+
+//go:noinline
+func assertToConcrete(v interface{}) LargeStruct {
+	return v.(LargeStruct) // Copies 24,000 bytes out of the box
+}
+
+//go:noinline
+func assertToPointer(v interface{}) *LargeStruct {
+	return v.(*LargeStruct) // Copies the pointer only
+}
@@ -0,0 +1,464 @@
+package heapescapeanalysis
+
+import (
+	"fmt"
+	"testing"
+)
+
+func BenchmarkComparison_SliceBoxing(b *testing.B) {
+	xs := make([]int, 16)
+
+	b.Run("Boxed", func(b *testing.B) {
+		var r interface{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = boxSlice(xs)
+		}
+		result = r
+	})
+
+	b.Run("Typed", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = typedSlice(xs)
+		}
+		result = r
+	})
+}
+
+func TestSliceBoxingAllocs(t *testing.T) {
+	xs := make([]int, 16)
+	var boxed interface{}
+	var typed []int
+
+	if allocs := testing.AllocsPerRun(100, func() { boxed = boxSlice(xs) }); allocs != 1 {
+		t.Errorf("boxSlice allocs = %v, want 1", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { typed = typedSlice(xs) }); allocs != 0 {
+		t.Errorf("typedSlice allocs = %v, want 0", allocs)
+	}
+	if len(boxed.([]int)) != len(typed) {
+		t.Error("boxed and typed slices differ")
+	}
+}
+
+func BenchmarkComparison_InterfaceEquality(b *testing.B) {
+	b.Run("Interface", func(b *testing.B) {
+		var r bool
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = compareInterfaces(i, i+1) // Both ints boxed on the stack
+		}
+		result = r
+	})
+
+	b.Run("Typed", func(b *testing.B) {
+		var r bool
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = compareInts(i, i+1)
+		}
+		result = r
+	})
+}
+
+func TestCompareInts(t *testing.T) {
+	for _, tt := range []struct{ a, b int }{{1, 1}, {1, 2}, {1000, 1000}, {-5, 5}} {
+		if got, want := compareInts(tt.a, tt.b), compareInterfaces(tt.a, tt.b); got != want {
+			t.Errorf("compareInts(%d, %d) = %v, compareInterfaces = %v", tt.a, tt.b, got, want)
+		}
+	}
+	if compareInterfaces(1, int64(1)) {
+		t.Error("compareInterfaces(int 1, int64 1) = true, want false: dynamic types differ")
+	}
+
+	var r bool
+	allocs := testing.AllocsPerRun(100, func() {
+		r = compareInts(1000, 1001)
+	})
+	result = r
+	if allocs != 0 {
+		t.Errorf("compareInts allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_InterfaceReturn(b *testing.B) {
+	b.Run("Stringer", func(b *testing.B) {
+		var r fmt.Stringer
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = asStringer(i, i)
+		}
+		result = r
+	})
+
+	b.Run("Concrete", func(b *testing.B) {
+		var r point
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = asPoint(i, i)
+		}
+		result = r
+	})
+}
+
+func TestInterfaceReturn(t *testing.T) {
+	if got, want := asStringer(3, 4).String(), asPoint(3, 4).String(); got != want || got != "(3, 4)" {
+		t.Errorf("asStringer(3, 4) = %q, asPoint(3, 4) = %q, want (3, 4)", got, want)
+	}
+
+	var p point
+	if allocs := testing.AllocsPerRun(100, func() { p = asPoint(3, 4) }); allocs != 0 {
+		t.Errorf("asPoint allocs = %v, want 0", allocs)
+	}
+	var s fmt.Stringer
+	if allocs := testing.AllocsPerRun(100, func() { s = asStringer(3, 4) }); allocs != 1 {
+		t.Errorf("asStringer allocs = %v, want 1", allocs)
+	}
+	if s.String() != p.String() {
+		t.Errorf("asStringer(3, 4) = %v, asPoint(3, 4) = %v", s, p)
+	}
+}
+
+func BenchmarkComparison_InterfaceMethodReturn(b *testing.B) {
+	b.Run("Interface-Pointer", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r += sensorByPointer.ReadPtr().Value
+		}
+		result = r
+	})
+
+	b.Run("Interface-Value", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r += sensorByValue.Read().Value
+		}
+		result = r
+	})
+
+	b.Run("Concrete-Pointer", func(b *testing.B) {
+		t := thermometer{offset: 1}
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r += t.ReadPtr().Value // Inlined, so the reading stays on the stack
+		}
+		result = r
+	})
+}
+
+func TestInterfaceMethodReturn(t *testing.T) {
+	if p, v := sensorByPointer.ReadPtr(), sensorByValue.Read(); *p != v {
+		t.Errorf("ReadPtr() = %+v, Read() = %+v", *p, v)
+	}
+
+	var r int
+	valueAllocs := testing.AllocsPerRun(100, func() { r += sensorByValue.Read().Value })
+	pointerAllocs := testing.AllocsPerRun(100, func() { r += sensorByPointer.ReadPtr().Value })
+	result = r
+	if valueAllocs != 0 {
+		t.Errorf("Read through interface allocs = %v, want 0", valueAllocs)
+	}
+	if pointerAllocs != 1 {
+		t.Errorf("ReadPtr through interface allocs = %v, want 1", pointerAllocs)
+	}
+}
+
+func BenchmarkComparison_LargeValueBoxing(b *testing.B) {
+	b.Run("Boxed", func(b *testing.B) {
+		var r interface{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = boxLargeValue(i)
+		}
+		result = r
+	})
+
+	b.Run("By-Value", func(b *testing.B) {
+		var r LargeStruct
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = largeValue(i)
+		}
+		result = r.data[0]
+	})
+}
+
+func TestLargeValueBoxing(t *testing.T) {
+	if got := boxLargeValue(7).(LargeStruct); got != largeValue(7) {
+		t.Error("boxLargeValue and largeValue differ")
+	}
+
+	var r LargeStruct
+	allocs := testing.AllocsPerRun(100, func() { r = largeValue(7) })
+	if allocs != 0 {
+		t.Errorf("largeValue allocs = %v, want 0", allocs)
+	}
+	if r.data[0] != 7 {
+		t.Errorf("largeValue(7).data[0] = %d", r.data[0])
+	}
+}
+
+func BenchmarkComparison_EmbeddedHandler(b *testing.B) {
+	b.Run("Embedded-Interface", func(b *testing.B) {
+		var r countingMiddleware
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = newCountingMiddleware(i, 1)
+		}
+		result = r
+	})
+
+	b.Run("Embedded-Concrete", func(b *testing.B) {
+		var r countingScaler
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = newCountingScaler(i, 1)
+		}
+		result = r
+	})
+}
+
+func TestEmbeddedHandler(t *testing.T) {
+	viaInterface, concrete := newCountingMiddleware(3, 1), newCountingScaler(3, 1)
+	if got, want := viaInterface.serve(5), concrete.serve(5); got != want || got != 16 {
+		t.Errorf("serve(5) = %d via interface, %d concrete, want 16", got, want)
+	}
+	if viaInterface.calls != 1 || concrete.calls != 1 {
+		t.Errorf("calls = %d and %d, want 1", viaInterface.calls, concrete.calls)
+	}
+
+	var r countingScaler
+	allocs := testing.AllocsPerRun(100, func() { r = newCountingScaler(3, 1) })
+	result = r
+	if allocs != 0 {
+		t.Errorf("newCountingScaler allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_LoggerArgs(b *testing.B) {
+	b.Run("Variadic-Interface", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logf("request failed %d", 1000+i)
+		}
+		result = logBuf
+	})
+
+	b.Run("Typed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			logInt("request failed", 1000+i)
+		}
+		result = logBuf
+	})
+}
+
+func TestLoggerArgs(t *testing.T) {
+	logf("request failed %d", 1500)
+	variadic := string(logBuf)
+	logInt("request failed", 1500)
+	if typed := string(logBuf); typed != variadic || typed != "request failed 1500" {
+		t.Errorf("logf wrote %q, logInt wrote %q, want %q", variadic, typed, "request failed 1500")
+	}
+
+	code := 1000 // Varied per call so the argument is not a constant
+	if allocs := testing.AllocsPerRun(100, func() { code++; logInt("request failed", code) }); allocs != 0 {
+		t.Errorf("logInt allocs = %v, want 0", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { code++; logf("request failed %d", code) }); allocs < 1 {
+		t.Errorf("logf allocs = %v, want the boxed argument on the heap", allocs)
+	}
+}
+
+func BenchmarkComparison_NilCheck(b *testing.B) {
+	var p *int
+
+	b.Run("Interface", func(b *testing.B) {
+		var r bool
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = checkInterfaceNil(p)
+		}
+		result = r
+	})
+
+	b.Run("Pointer", func(b *testing.B) {
+		var r bool
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = checkPointerNil(p)
+		}
+		result = r
+	})
+}
+
+func TestTypedNilInInterface(t *testing.T) {
+	var p *int
+	if checkInterfaceNil(p) {
+		t.Error("checkInterfaceNil(nil *int) = true, want false: the interface holds type *int")
+	}
+	if !checkPointerNil(p) {
+		t.Error("checkPointerNil(nil) = false, want true")
+	}
+	if !checkInterfaceNil(nil) {
+		t.Error("checkInterfaceNil(nil) = false, want true")
+	}
+
+	var r bool
+	allocs := testing.AllocsPerRun(100, func() { r = checkInterfaceNil(p) })
+	result = r
+	if allocs != 0 {
+		t.Errorf("checkInterfaceNil(*int) allocs = %v, want 0: pointers are not boxed", allocs)
+	}
+}
+
+func BenchmarkComparison_VariadicStructs(b *testing.B) {
+	b.Run("Variadic-Interface", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			passStructsVariadic(point{X: i, Y: 1}, point{X: i, Y: 2}, point{X: i, Y: 3})
+		}
+	})
+
+	b.Run("Typed-Slice", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			passStructsTyped(point{X: i, Y: 1}, point{X: i, Y: 2}, point{X: i, Y: 3})
+		}
+	})
+}
+
+func TestVariadicStructs(t *testing.T) {
+	passStructsVariadic(point{X: 1, Y: 2}, point{X: 3, Y: 4})
+	passStructsTyped(point{X: 1, Y: 2}, point{X: 3, Y: 4})
+	if len(recordedValues) != 2 || recordedValues[1] != (point{X: 3, Y: 4}) {
+		t.Errorf("recordedValues = %v, want [(1, 2) (3, 4)]", recordedValues)
+	}
+	if len(recordedPoints) != 2 || recordedPoints[1] != (point{X: 3, Y: 4}) {
+		t.Errorf("recordedPoints = %v, want [(1, 2) (3, 4)]", recordedPoints)
+	}
+
+	x := 0
+	typed := testing.AllocsPerRun(100, func() {
+		x++
+		passStructsTyped(point{X: x, Y: 1}, point{X: x, Y: 2}, point{X: x, Y: 3})
+	})
+	variadic := testing.AllocsPerRun(100, func() {
+		x++
+		passStructsVariadic(point{X: x, Y: 1}, point{X: x, Y: 2}, point{X: x, Y: 3})
+	})
+	if typed != 0 {
+		t.Errorf("passStructsTyped allocs = %v, want 0", typed)
+	}
+	if variadic != 4 {
+		t.Errorf("passStructsVariadic allocs = %v, want 4 (the slice and three boxes)", variadic)
+	}
+}
+
+func BenchmarkComparison_ShapeFactory(b *testing.B) {
+	b.Run("Factory-Map", func(b *testing.B) {
+		var r float64
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = factoryFromMap("square", float64(i)).Area()
+		}
+		result = r
+	})
+
+	b.Run("Switch", func(b *testing.B) {
+		var r float64
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = areaFromSwitch("square", float64(i))
+		}
+		result = r
+	})
+}
+
+func TestShapeFactory(t *testing.T) {
+	for _, kind := range []string{"circle", "square"} {
+		if got, want := factoryFromMap(kind, 3).Area(), areaFromSwitch(kind, 3); got != want {
+			t.Errorf("%s: factory area = %v, switch area = %v", kind, got, want)
+		}
+	}
+	if s := factoryFromMap("hexagon", 1); s != nil {
+		t.Errorf("factoryFromMap(hexagon) = %v, want nil", s)
+	}
+	if got := areaFromSwitch("hexagon", 1); got != 0 {
+		t.Errorf("areaFromSwitch(hexagon) = %v, want 0", got)
+	}
+
+	var r float64
+	size := 1.0
+	factory := testing.AllocsPerRun(100, func() { size++; r = factoryFromMap("square", size).Area() })
+	direct := testing.AllocsPerRun(100, func() { size++; r = areaFromSwitch("square", size) })
+	result = r
+	if factory != 1 {
+		t.Errorf("factoryFromMap allocs = %v, want 1 (the boxed rect)", factory)
+	}
+	if direct != 0 {
+		t.Errorf("areaFromSwitch allocs = %v, want 0", direct)
+	}
+}
+
+func BenchmarkComparison_AssertLarge(b *testing.B) {
+	boxedValue := boxLargeValue(7)
+	boxedPointer := interface{}(&LargeStruct{})
+
+	b.Run("Value-Assertion", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s := assertToConcrete(boxedValue)
+			r += s.data[0]
+		}
+		result = r
+	})
+
+	b.Run("Pointer-Assertion", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r += assertToPointer(boxedPointer).data[0]
+		}
+		result = r
+	})
+}
+
+func TestAssertLarge(t *testing.T) {
+	boxed := boxLargeValue(7)
+	s := assertToConcrete(boxed)
+	if s.data[0] != 7 {
+		t.Errorf("assertToConcrete(...).data[0] = %d, want 7", s.data[0])
+	}
+	s.data[0] = 8
+	if again := assertToConcrete(boxed); again.data[0] != 7 {
+		t.Errorf("modifying the asserted copy changed the box to %d", again.data[0])
+	}
+
+	orig := &LargeStruct{}
+	orig.data[0] = 7
+	p := assertToPointer(orig)
+	if p != orig {
+		t.Error("assertToPointer returned a different pointer")
+	}
+
+	var r int
+	allocs := testing.AllocsPerRun(10, func() { r = assertToConcrete(boxed).data[0] })
+	result = r
+	if allocs != 0 {
+		t.Errorf("assertToConcrete allocs = %v, want 0: the copy goes to the caller's frame", allocs)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("assertToConcrete on a *LargeStruct did not panic")
+		}
+	}()
+	assertToConcrete(orig)
+}
@@ -1,7 +1,5 @@
 package heapescapeanalysis
 
-import "sync"
-
 // Stack-friendly techniques to avoid heap allocation
 
 // 1. Keep variables local and don't return pointers
@@ -74,11 +72,9 @@ func directValueAccess(data [5]int) int {
 }
 
 // 8. Use sync.Pool for frequently allocated objects to reduce heap pressure
-var largeStructPool = sync.Pool{
-	New: func() interface{} {
-		return &LargeStruct{}
-	},
-}
+var largeStructPool = newStatsPool(func() interface{} {
+	return &LargeStruct{}
+})
 
 //go:noinline
 func useSyncPool() *LargeStruct {
@@ -216,7 +212,9 @@ func sliceGrowth() []int {
 	return slice
 }
 
-// Heap allocation - map for small lookup
+// Slow lookup - map built on every call. With 4 entries it fits on the stack
+// (see tinyMapLiteral), but building and hashing it still costs far more than
+// indexing an array.
 //
 //go:noinline
 func mapLookup(key int) string {
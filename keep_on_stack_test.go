@@ -0,0 +1,44 @@
+package heapescapeanalysis
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestStackFriendlyFunctionsStayOnStack backs the claim of keep_on_stack.go:
+// no local variable of a stackFriendly* (or *StackFriendly*) function is
+// moved to the heap. Functions are found from the compiler's own notes, so
+// new stack-friendly examples are checked without updating this test.
+func TestStackFriendlyFunctionsStayOnStack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the compiler")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	notes, err := RunEscapeAnalysis(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var checked []string
+	seen := make(map[string]bool)
+	for _, n := range notes {
+		m := inlineDeclRE.FindStringSubmatch(n.Message)
+		if m == nil || seen[m[1]] || !strings.Contains(strings.ToLower(m[1]), "stackfriendly") {
+			continue
+		}
+		fn := m[1]
+		seen[fn] = true
+		checked = append(checked, fn)
+		for _, fnNote := range notesForFunc(notes, fn) {
+			if movedToHeapRE.MatchString(fnNote.Message) {
+				t.Errorf("%s: %s", fn, fnNote)
+			}
+		}
+	}
+	if len(checked) < 3 {
+		t.Errorf("checked %d stack-friendly functions (%v), want at least 3", len(checked), checked)
+	}
+}
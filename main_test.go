@@ -0,0 +1,147 @@
+package heapescapeanalysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestMain prints a table correlating every benchmark with the escape
+// verdicts of the package functions it calls when ANNOTATE_ESCAPES=1, e.g.
+//
+//	ANNOTATE_ESCAPES=1 go test -run '^$' -bench .
+//
+// so the allocs/op of each benchmark can be read next to the compiler's
+// explanation. go test only shows the table with -bench or -v, as with any
+// output of a passing package. It runs the compiler once, so it is off by
+// default.
+func TestMain(m *testing.M) {
+	if os.Getenv("ANNOTATE_ESCAPES") == "1" {
+		if err := printBenchmarkEscapes(os.Stdout, "."); err != nil {
+			fmt.Fprintf(os.Stderr, "ANNOTATE_ESCAPES: %v\n", err)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+// printBenchmarkEscapes writes the ANNOTATE_ESCAPES table for the package in
+// dir to w.
+func printBenchmarkEscapes(w io.Writer, dir string) error {
+	notes, err := RunEscapeAnalysis(dir)
+	if err != nil {
+		return err
+	}
+	callees, err := benchmarkCallees(dir)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, benchmarkEscapeTable(callees, notes))
+	return err
+}
+
+// benchmarkCallees parses the _test.go files in dir and returns, for each
+// Benchmark function, the package-level functions it calls directly or in
+// its sub-benchmarks, in order of first call.
+func benchmarkCallees(dir string) (map[string][]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	callees := make(map[string][]string)
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Recv != nil || !strings.HasPrefix(fd.Name.Name, "Benchmark") {
+				continue
+			}
+			seen := make(map[string]bool)
+			ast.Inspect(fd.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				fun := call.Fun
+				if idx, ok := fun.(*ast.IndexExpr); ok {
+					fun = idx.X // Generic instantiation: newOf[int]()
+				}
+				if id, ok := fun.(*ast.Ident); ok && !seen[id.Name] {
+					seen[id.Name] = true
+					callees[fd.Name.Name] = append(callees[fd.Name.Name], id.Name)
+				}
+				return true
+			})
+		}
+	}
+	return callees, nil
+}
+
+// benchmarkEscapeTable renders one markdown row per benchmark and callee
+// the compiler reported on. Builtins, test helpers and other names without
+// escape notes are left out.
+func benchmarkEscapeTable(callees map[string][]string, notes []EscapeNote) string {
+	benchmarks := make([]string, 0, len(callees))
+	for b := range callees {
+		benchmarks = append(benchmarks, b)
+	}
+	sort.Strings(benchmarks)
+
+	var sb strings.Builder
+	sb.WriteString("| Benchmark | Function | Verdict | Heap allocated |\n")
+	sb.WriteString("|-----------|----------|---------|----------------|\n")
+	for _, b := range benchmarks {
+		for _, fn := range callees[b] {
+			if len(notesForFunc(notes, fn)) == 0 {
+				continue
+			}
+			verdict, allocated := escapeVerdictCells(notes, fn)
+			fmt.Fprintf(&sb, "| `%s` | `%s` | %s | %s |\n", b, fn, verdict, allocated)
+		}
+	}
+	return sb.String()
+}
+
+func TestBenchmarkEscapeCorrelation(t *testing.T) {
+	callees, err := benchmarkCallees(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := callees["BenchmarkReturnLargeValue"]; len(got) != 1 || got[0] != "returnLargeValue" {
+		t.Errorf("BenchmarkReturnLargeValue calls %v, want [returnLargeValue]", got)
+	}
+
+	if testing.Short() {
+		return
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	notes, err := RunEscapeAnalysis(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := benchmarkEscapeTable(callees, notes)
+	for _, row := range []string{
+		"| `BenchmarkReturnLargeValue` | `returnLargeValue` | stack |  |",
+		"| `BenchmarkReturnLargePointer` | `returnLargePointer` | heap | `s` |",
+	} {
+		if !strings.Contains(table, row) {
+			t.Errorf("table is missing row %q", row)
+		}
+	}
+	if strings.Contains(table, "`make`") {
+		t.Error("table includes the make builtin")
+	}
+}
@@ -0,0 +1,391 @@
+package heapescapeanalysis
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Building map keys in hot cache paths
+//
+// Every key constructed for a map operation is a fresh string. fmt.Sprintf
+// boxes its arguments into interface{} and builds the result through a
+// printer state, so it costs more than strconv for the same key. When a key
+// is only needed for a lookup, appending it into a reused buffer and indexing
+// with m[string(buf)] avoids the allocation entirely: the compiler recognises
+// that conversion and does not copy the bytes.
+
+//go:noinline
+func mapKeyFromSprintf(id int) string {
+	return fmt.Sprintf("user:%d", id) // Boxes id, allocates the result
+}
+
+//go:noinline
+func mapKeyFromStrconv(id int) string {
+	var buf [32]byte // Scratch space stays on the stack
+	b := append(buf[:0], "user:"...)
+	b = strconv.AppendInt(b, int64(id), 10)
+	return string(b) // Single allocation for the result
+}
+
+// lookupByAppendedKey builds the key into buf and looks it up without
+// allocating. buf is returned so callers can keep reusing its capacity.
+//
+//go:noinline
+func lookupByAppendedKey(m map[string]int, buf []byte, id int) (int, []byte) {
+	buf = append(buf[:0], "user:"...)
+	buf = strconv.AppendInt(buf, int64(id), 10)
+	return m[string(buf)], buf // No allocation for the conversion
+}
+
+// Sizing maps with make(map, hint)
+//
+// A map created without a hint starts with room for only a few entries and
+// grows as it fills: each growth allocates larger bucket storage (groups of
+// slots in the Swiss-table maps used since Go 1.24) and moves the existing
+// entries into it, leaving the old storage as garbage. Passing the
+// expected size to make allocates a table big enough up front, the map
+// equivalent of make([]T, 0, n).
+
+//go:noinline
+func mapNoHint(n int) map[int]int {
+	m := make(map[int]int) // Grows repeatedly while filling
+	for i := 0; i < n; i++ {
+		m[i] = i * i
+	}
+	return m
+}
+
+//go:noinline
+func mapWithHint(n int) map[int]int {
+	m := make(map[int]int, n) // Sized once for n entries
+	for i := 0; i < n; i++ {
+		m[i] = i * i
+	}
+	return m
+}
+
+// Grouping into a map of slices
+//
+// Appending each item to a nil inner slice grows every group from scratch:
+// each one goes through the usual 1, 2, 4, 8... capacity steps, so with many
+// items per group most of the allocations are discarded intermediate arrays.
+// Counting first and presizing each inner slice costs an extra pass over the
+// input but allocates every group exactly once.
+
+// Item is a value tagged with the group it belongs to.
+type Item struct {
+	Group string
+	Value int
+}
+
+//go:noinline
+func groupByNaive(items []Item) map[string][]int {
+	groups := make(map[string][]int)
+	for _, it := range items {
+		groups[it.Group] = append(groups[it.Group], it.Value) // Regrows each group
+	}
+	return groups
+}
+
+//go:noinline
+func groupByPrealloc(items []Item) map[string][]int {
+	counts := make(map[string]int)
+	for _, it := range items {
+		counts[it.Group]++
+	}
+	groups := make(map[string][]int, len(counts))
+	for g, n := range counts {
+		groups[g] = make([]int, 0, n) // Exact size for each group
+	}
+	for _, it := range items {
+		groups[it.Group] = append(groups[it.Group], it.Value)
+	}
+	return groups
+}
+
+// Small map literals on the stack
+//
+// A map that does not escape can have its header and first group of slots
+// (8 entries) allocated in the caller's frame, so tinyMapLiteral builds and
+// queries its map without touching the heap. The escape-analysis note for a
+// map literal only says "does not escape"; it does not say how much of the
+// map fits in the frame. biggerMapLiteral's map does not escape either, but
+// 64 entries outgrow the stack-allocated group, so the runtime allocates
+// the table on the heap as the literal is filled in. The 8-entry threshold
+// is an implementation detail of the runtime's map layout and has changed
+// with it (Go 1.24 replaced buckets with Swiss-table groups), so pin it with
+// a test rather than relying on it silently.
+
+//go:noinline
+func tinyMapLiteral(key string) int {
+	m := map[string]int{"a": 1, "b": 2} // Stack: fits in one group
+	return m[key]
+}
+
+//go:noinline
+func biggerMapLiteral(key int) int {
+	m := map[int]int{ // Does not escape, but the table is on the heap
+		0: 0, 1: 1, 2: 4, 3: 9, 4: 16, 5: 25, 6: 36, 7: 49,
+		8: 64, 9: 81, 10: 100, 11: 121, 12: 144, 13: 169, 14: 196, 15: 225,
+		16: 256, 17: 289, 18: 324, 19: 361, 20: 400, 21: 441, 22: 484, 23: 529,
+		24: 576, 25: 625, 26: 676, 27: 729, 28: 784, 29: 841, 30: 900, 31: 961,
+		32: 1024, 33: 1089, 34: 1156, 35: 1225, 36: 1296, 37: 1369, 38: 1444, 39: 1521,
+		40: 1600, 41: 1681, 42: 1764, 43: 1849, 44: 1936, 45: 2025, 46: 2116, 47: 2209,
+		48: 2304, 49: 2401, 50: 2500, 51: 2601, 52: 2704, 53: 2809, 54: 2916, 55: 3025,
+		56: 3136, 57: 3249, 58: 3364, 59: 3481, 60: 3600, 61: 3721, 62: 3844, 63: 3969,
+	}
+	return m[key]
+}
+
+// Building a fresh map versus clearing and refilling one
+//
+// A map returned from a function escapes, and filling 1000 entries grows it
+// through several table doublings, each one a new allocation. A handler that
+// builds such a map on every request can keep one instead: clear removes
+// every entry but keeps the allocated table, so refilling to the same size
+// allocates nothing. The tradeoff is retention. The table never shrinks, so
+// after one unusually large request the reused map holds that much memory
+// for good, and clearing a huge, mostly empty table still costs time
+// proportional to its capacity. Drop and reallocate the map if its size
+// varies wildly.
+
+//go:noinline
+func buildMapInLoop(n int) map[int]int {
+	m := make(map[int]int) // Escapes: returned, and grows as it fills
+	for i := 0; i < n; i++ {
+		m[i] = i * i
+	}
+	return m
+}
+
+// buildMapReusing refills *m with the same entries as buildMapInLoop,
+// allocating the map only if *m is nil.
+//
+//go:noinline
+func buildMapReusing(m *map[int]int, n int) {
+	if *m == nil {
+		*m = make(map[int]int, n)
+	}
+	clear(*m) // Keeps the table
+	for i := 0; i < n; i++ {
+		(*m)[i] = i * i
+	}
+}
+
+// clear versus a presized fresh map
+//
+// buildMapInLoop pays for growth as well as for the allocation. Even a map
+// made with the right size hint, as reallocMap does, still allocates its
+// header and table storage every time, and for a small per-request map that
+// is a large share of the work: making a 100-entry map and filling it is
+// measurably slower than clearing and refilling a kept one. The memory
+// caveat of buildMapReusing applies here too: clear empties the slots but
+// keeps every table and group the map has ever grown, so a kept map is only
+// as small as its largest use.
+
+// perRequestMapSize is the number of entries the map comparison stores.
+const perRequestMapSize = 100
+
+//go:noinline
+func clearMapBuiltin(m map[int]int) {
+	clear(m) // Empties the slots, keeps the memory
+}
+
+//go:noinline
+func reallocMap() map[int]int {
+	return make(map[int]int, perRequestMapSize) // Header and tables allocated per call
+}
+
+// Map values are not addressable
+//
+// &m[k] does not compile: map entries move when the table grows, so a
+// pointer to one would dangle, and m[k].field = v is rejected for the same
+// reason. A struct-valued map therefore has to be updated by copying the
+// entry out, modifying the copy and storing it back, which for LargeStruct
+// moves 24KB each way. (Values larger than 128 bytes are not even stored
+// inline: the map keeps a pointer to a separately allocated copy, so each
+// insert of a new key allocates regardless.) Storing *LargeStruct instead
+// costs one heap allocation per entry up front, after which updates go
+// through the pointer in place, with no copying at all.
+
+// copyOutMapValue sets data[0] of entry k to v by copying the entry out and
+// storing the modified copy back.
+//
+//go:noinline
+func copyOutMapValue(m map[int]LargeStruct, k, v int) {
+	s := m[k] // Copies 24KB out of the map
+	s.data[0] = v
+	m[k] = s // And 24KB back in
+}
+
+// storePointersInMap returns a map with n entries, each pointing at its own
+// heap-allocated LargeStruct.
+//
+//go:noinline
+func storePointersInMap(n int) map[int]*LargeStruct {
+	m := make(map[int]*LargeStruct, n)
+	for i := 0; i < n; i++ {
+		m[i] = &LargeStruct{} // Escapes: stored in the map
+	}
+	return m
+}
+
+// setViaMapPointer sets data[0] of entry k to v in place.
+//
+//go:noinline
+func setViaMapPointer(m map[int]*LargeStruct, k, v int) {
+	m[k].data[0] = v // Allowed: the pointer, not the entry, is dereferenced
+}
+
+// Fixed-size array keys versus string keys
+//
+// Identifiers that are fixed-width binary values, such as 16-byte UUIDs, are
+// often converted to strings to key a map. Every insert of a new key then
+// has to allocate the string, because the map keeps it. (Lookups with
+// m[string(b)] are optimized and do not allocate.) A [16]byte is a
+// comparable value type, so map[[16]byte]int stores the key inline in the
+// table: inserts copy 16 bytes and allocate nothing beyond the table itself,
+// and hashing it costs about the same as hashing a 16-byte string.
+
+//go:noinline
+func arrayKeyMap(ids [][16]byte) int {
+	m := make(map[[16]byte]int, len(ids))
+	for i, id := range ids {
+		m[id] = i // Key copied into the table
+	}
+	sum := 0
+	for _, id := range ids {
+		sum += m[id]
+	}
+	return sum
+}
+
+//go:noinline
+func stringKeyMap(ids [][16]byte) int {
+	m := make(map[string]int, len(ids))
+	for i, id := range ids {
+		m[string(id[:])] = i // Allocates the key string
+	}
+	sum := 0
+	for _, id := range ids {
+		sum += m[string(id[:])] // Lookup conversion does not allocate
+	}
+	return sum
+}
+
+// interface{}-valued caches versus typed caches
+//
+// A cache declared as map[string]interface{} can hold anything, so every
+// value stored in it is boxed: an int outside the runtime's small-int cache
+// is copied into a new 8-byte heap object on each store, and the map holds a
+// pointer to that box rather than the int itself. Reading it back is cheap,
+// since cacheGetBoxed returns the existing box without allocating, but every
+// caller then needs a type assertion, and each value keeps a separate heap
+// object alive for the collector to scan. A typed map[string]int stores the
+// value inline and allocates nothing once the key exists. When one cache
+// implementation must serve several value types, a generic Cache[V] keeps
+// that property for each V, where interface{} gives it up for all of them.
+
+var (
+	boxedCache = make(map[string]interface{})
+	intCache   = make(map[string]int)
+)
+
+//go:noinline
+func cacheSetBoxed(k string, v int) {
+	boxedCache[k] = v // Boxes v on every store
+}
+
+//go:noinline
+func cacheGetBoxed(k string) interface{} {
+	return boxedCache[k] // Returns the stored box; callers must assert
+}
+
+//go:noinline
+func cacheSetInt(k string, v int) {
+	intCache[k] = v // Stored inline
+}
+
+//go:noinline
+func cacheGetInt(k string) (int, bool) {
+	v, ok := intCache[k]
+	return v, ok
+}
+
+// Bit-packed flags versus map[string]bool
+//
+// A small, fixed set of options is often tracked as map[string]bool. Each
+// set then hashes a string and may grow the table, and once the map holds
+// more entries than fit in the compiler's stack-allocated table (see
+// tinyMapLiteral) it is on the heap. When the flags are known at compile
+// time, each can be a bit in a uint64: Set is an OR, Has is an AND, and the
+// whole set is a single integer that lives in a register or a stack slot,
+// copies for free and never allocates. Up to 64 flags fit in a uint64; past
+// that, a fixed-size array of words works the same way.
+
+// option identifies one bit of a bitFlags set.
+type option uint
+
+const (
+	optVerbose option = iota
+	optDryRun
+	optForce
+	optRecursive
+	optQuiet
+	optColor
+	optCache
+	optParallel
+	optStrict
+	optTrace
+	numOptions
+)
+
+// optionNames are the map[string]bool keys for each option.
+var optionNames = [numOptions]string{
+	"verbose", "dry-run", "force", "recursive", "quiet",
+	"color", "cache", "parallel", "strict", "trace",
+}
+
+// bitFlags is a set of options packed into one word.
+type bitFlags uint64
+
+// Set adds o to the set.
+func (f *bitFlags) Set(o option) { *f |= 1 << o }
+
+// Has reports whether o is in the set.
+func (f bitFlags) Has(o option) bool { return f&(1<<o) != 0 }
+
+// setAndCheckBits sets every option in opts and returns how many of all the
+// options are then set.
+//
+//go:noinline
+func setAndCheckBits(opts []option) int {
+	var f bitFlags // One word on the stack
+	for _, o := range opts {
+		f.Set(o)
+	}
+	n := 0
+	for o := option(0); o < numOptions; o++ {
+		if f.Has(o) {
+			n++
+		}
+	}
+	return n
+}
+
+// setAndCheckMap is setAndCheckBits using a map[string]bool.
+//
+//go:noinline
+func setAndCheckMap(opts []option) int {
+	f := make(map[string]bool) // Grows past its stack table at 9 entries
+	for _, o := range opts {
+		f[optionNames[o]] = true
+	}
+	n := 0
+	for _, name := range optionNames {
+		if f[name] {
+			n++
+		}
+	}
+	return n
+}
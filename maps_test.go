@@ -0,0 +1,559 @@
+package heapescapeanalysis
+
+import (
+	"encoding/binary"
+	"maps"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const mapKeyCount = 100
+
+func BenchmarkComparison_MapKeyConstruction(b *testing.B) {
+	b.Run("Sprintf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m := make(map[string]int, mapKeyCount)
+			for id := 0; id < mapKeyCount; id++ {
+				m[mapKeyFromSprintf(id)] = id
+			}
+			result = m
+		}
+	})
+
+	b.Run("Strconv", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m := make(map[string]int, mapKeyCount)
+			for id := 0; id < mapKeyCount; id++ {
+				m[mapKeyFromStrconv(id)] = id
+			}
+			result = m
+		}
+	})
+}
+
+func BenchmarkLookupByAppendedKey(b *testing.B) {
+	m := make(map[string]int, mapKeyCount)
+	for id := 0; id < mapKeyCount; id++ {
+		m[mapKeyFromStrconv(id)] = id
+	}
+	buf := make([]byte, 0, 32)
+	var r int
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, buf = lookupByAppendedKey(m, buf, i%mapKeyCount)
+	}
+	result = r
+}
+
+func TestMapKeyConstruction(t *testing.T) {
+	for _, id := range []int{0, 7, 123, 98765} {
+		if got, want := mapKeyFromStrconv(id), mapKeyFromSprintf(id); got != want {
+			t.Errorf("mapKeyFromStrconv(%d) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestMapKeyFromStrconvAllocs(t *testing.T) {
+	const id = 12345
+	var key string
+	strconvAllocs := testing.AllocsPerRun(100, func() {
+		key = mapKeyFromStrconv(id)
+	})
+	sprintfAllocs := testing.AllocsPerRun(100, func() {
+		key = mapKeyFromSprintf(id)
+	})
+	result = key
+	if strconvAllocs > 1 {
+		t.Errorf("mapKeyFromStrconv allocs = %v, want <= 1", strconvAllocs)
+	}
+	if strconvAllocs >= sprintfAllocs {
+		t.Errorf("mapKeyFromStrconv allocs = %v, want fewer than mapKeyFromSprintf (%v)", strconvAllocs, sprintfAllocs)
+	}
+}
+
+func TestLookupByAppendedKeyAllocs(t *testing.T) {
+	m := map[string]int{mapKeyFromStrconv(42): 42}
+	buf := make([]byte, 0, 32)
+
+	var got int
+	allocs := testing.AllocsPerRun(100, func() {
+		got, buf = lookupByAppendedKey(m, buf, 42)
+	})
+	if got != 42 {
+		t.Errorf("lookupByAppendedKey = %d, want 42", got)
+	}
+	if allocs != 0 {
+		t.Errorf("lookupByAppendedKey allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_MapSizeHint(b *testing.B) {
+	b.Run("No-Hint", func(b *testing.B) {
+		var r map[int]int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = mapNoHint(1000)
+		}
+		result = r
+	})
+
+	b.Run("With-Hint", func(b *testing.B) {
+		var r map[int]int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = mapWithHint(1000)
+		}
+		result = r
+	})
+}
+
+func TestMapSizeHint(t *testing.T) {
+	noHint, withHint := mapNoHint(1000), mapWithHint(1000)
+	if len(withHint) != 1000 || !maps.Equal(noHint, withHint) {
+		t.Errorf("mapNoHint and mapWithHint differ (len %d and %d)", len(noHint), len(withHint))
+	}
+}
+
+func groupItems(n, groups int) []Item {
+	items := make([]Item, n)
+	for i := range items {
+		items[i] = Item{Group: "group-" + strconv.Itoa(i%groups), Value: i}
+	}
+	return items
+}
+
+func BenchmarkComparison_GroupBy(b *testing.B) {
+	items := groupItems(1000, 10)
+
+	b.Run("Naive", func(b *testing.B) {
+		var r map[string][]int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = groupByNaive(items)
+		}
+		result = r
+	})
+
+	b.Run("Preallocated", func(b *testing.B) {
+		var r map[string][]int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = groupByPrealloc(items)
+		}
+		result = r
+	})
+}
+
+func TestGroupBy(t *testing.T) {
+	items := groupItems(1000, 10)
+	naive, prealloc := groupByNaive(items), groupByPrealloc(items)
+	if len(prealloc) != 10 || !maps.EqualFunc(naive, prealloc, slices.Equal[[]int]) {
+		t.Errorf("groupByNaive and groupByPrealloc differ (%d and %d groups)", len(naive), len(prealloc))
+	}
+	for g, vs := range prealloc {
+		if len(vs) != cap(vs) {
+			t.Errorf("group %s: len %d, cap %d, want exact presizing", g, len(vs), cap(vs))
+		}
+	}
+}
+
+func BenchmarkComparison_MapLiteralSize(b *testing.B) {
+	b.Run("Tiny-2", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = tinyMapLiteral("b")
+		}
+		result = r
+	})
+
+	b.Run("Bigger-64", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = biggerMapLiteral(7)
+		}
+		result = r
+	})
+}
+
+func TestMapLiteralSize(t *testing.T) {
+	if got := tinyMapLiteral("b"); got != 2 {
+		t.Errorf("tinyMapLiteral(b) = %d, want 2", got)
+	}
+	if got := biggerMapLiteral(7); got != 49 {
+		t.Errorf("biggerMapLiteral(7) = %d, want 49", got)
+	}
+
+	var r int
+	tiny := testing.AllocsPerRun(100, func() { r = tinyMapLiteral("b") })
+	bigger := testing.AllocsPerRun(100, func() { r = biggerMapLiteral(7) })
+	result = r
+	if tiny != 0 {
+		t.Errorf("tinyMapLiteral allocs = %v, want 0: the map should fit on the stack", tiny)
+	}
+	if bigger == 0 {
+		t.Error("biggerMapLiteral allocs = 0, want its 64 entries on the heap")
+	}
+
+	if testing.Short() {
+		return
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	notes, err := RunEscapeAnalysis(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The compiler reports both literals as non-escaping; only the runtime
+	// allocations above tell them apart.
+	for _, fn := range []string{"tinyMapLiteral", "biggerMapLiteral"} {
+		var found bool
+		for _, n := range notesForFunc(notes, fn) {
+			if strings.HasPrefix(n.Message, "map[") {
+				found = true
+				if !strings.HasSuffix(n.Message, "does not escape") {
+					t.Errorf("%s: %s, want the map literal not to escape", fn, n.Message)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: no escape note for its map literal", fn)
+		}
+	}
+}
+
+func BenchmarkComparison_MapReuse(b *testing.B) {
+	b.Run("Fresh-Map", func(b *testing.B) {
+		var r map[int]int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = buildMapInLoop(1000)
+		}
+		result = r
+	})
+
+	b.Run("Cleared-Map", func(b *testing.B) {
+		var m map[int]int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buildMapReusing(&m, 1000)
+		}
+		result = m
+	})
+}
+
+func TestMapReuse(t *testing.T) {
+	var m map[int]int
+	buildMapReusing(&m, 1000)
+	if fresh := buildMapInLoop(1000); !maps.Equal(fresh, m) {
+		t.Errorf("buildMapReusing differs from buildMapInLoop (%d and %d entries)", len(m), len(fresh))
+	}
+
+	buildMapReusing(&m, 10)
+	if len(m) != 10 {
+		t.Errorf("len after refilling with 10 = %d, want 10: clear should remove old entries", len(m))
+	}
+	if _, ok := m[500]; ok {
+		t.Error("entry 500 survived clear")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() { buildMapReusing(&m, 1000) })
+	if allocs != 0 {
+		t.Errorf("buildMapReusing allocs = %v, want 0 once the map is allocated", allocs)
+	}
+}
+
+// fillMap stores n entries in m.
+func fillMap(m map[int]int, n int) {
+	for i := 0; i < n; i++ {
+		m[i] = i
+	}
+}
+
+func BenchmarkComparison_MapClearVsRealloc(b *testing.B) {
+	b.Run("Clear", func(b *testing.B) {
+		m := make(map[int]int, perRequestMapSize)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			clearMapBuiltin(m)
+			fillMap(m, perRequestMapSize)
+		}
+		result = m
+	})
+
+	b.Run("Realloc", func(b *testing.B) {
+		var m map[int]int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m = reallocMap()
+			fillMap(m, perRequestMapSize)
+		}
+		result = m
+	})
+}
+
+func TestClearMapBuiltin(t *testing.T) {
+	m := reallocMap()
+	fillMap(m, perRequestMapSize)
+	clearMapBuiltin(m)
+	if len(m) != 0 {
+		t.Fatalf("len after clear = %d, want 0", len(m))
+	}
+	for k := range m {
+		t.Fatalf("entry %d survived clear", k)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		clearMapBuiltin(m)
+		fillMap(m, perRequestMapSize)
+	})
+	if allocs != 0 {
+		t.Errorf("clear and refill allocs = %v, want 0", allocs)
+	}
+
+	var fresh map[int]int
+	allocs = testing.AllocsPerRun(100, func() { fresh = reallocMap() })
+	result = fresh
+	if allocs == 0 {
+		t.Error("reallocMap allocs = 0, want its header and table storage on the heap")
+	}
+}
+
+func BenchmarkComparison_MapValueUpdate(b *testing.B) {
+	b.Run("Copy-Out-Store-Back", func(b *testing.B) {
+		m := map[int]LargeStruct{0: {}}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			copyOutMapValue(m, 0, i)
+		}
+		result = m[0].data[0]
+	})
+
+	b.Run("Pointer-Values", func(b *testing.B) {
+		m := storePointersInMap(1)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			setViaMapPointer(m, 0, i)
+		}
+		result = m[0].data[0]
+	})
+}
+
+func TestMapValueUpdate(t *testing.T) {
+	values := map[int]LargeStruct{0: {}, 1: {}}
+	copyOutMapValue(values, 1, 42)
+	if values[1].data[0] != 42 || values[0].data[0] != 0 {
+		t.Errorf("after copyOutMapValue(1, 42): entries = %d, %d; want 0, 42", values[0].data[0], values[1].data[0])
+	}
+
+	pointers := storePointersInMap(2)
+	if len(pointers) != 2 || pointers[0] == pointers[1] {
+		t.Fatalf("storePointersInMap(2) = %v, want 2 distinct entries", pointers)
+	}
+	p := pointers[1]
+	setViaMapPointer(pointers, 1, 42)
+	if p.data[0] != 42 {
+		t.Errorf("setViaMapPointer did not update the shared struct in place: data[0] = %d", p.data[0])
+	}
+
+	update := testing.AllocsPerRun(10, func() { copyOutMapValue(values, 1, 7) })
+	if update != 0 {
+		t.Errorf("copyOutMapValue allocs for an existing key = %v, want 0", update)
+	}
+	var r map[int]*LargeStruct
+	build := testing.AllocsPerRun(10, func() { r = storePointersInMap(4) })
+	result = r
+	if build < 4 {
+		t.Errorf("storePointersInMap(4) allocs = %v, want at least one per entry", build)
+	}
+}
+
+// uuidLikeIDs returns n distinct 16-byte identifiers.
+func uuidLikeIDs(n int) [][16]byte {
+	ids := make([][16]byte, n)
+	for i := range ids {
+		binary.BigEndian.PutUint64(ids[i][:8], uint64(i)*0x9e3779b97f4a7c15)
+		binary.BigEndian.PutUint64(ids[i][8:], uint64(i))
+	}
+	return ids
+}
+
+func BenchmarkComparison_ArrayVsStringKeys(b *testing.B) {
+	ids := uuidLikeIDs(100)
+
+	b.Run("Array-Key", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = arrayKeyMap(ids)
+		}
+		result = r
+	})
+
+	b.Run("String-Key", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = stringKeyMap(ids)
+		}
+		result = r
+	})
+}
+
+func TestArrayVsStringKeys(t *testing.T) {
+	ids := uuidLikeIDs(100)
+	want := 99 * 100 / 2
+	if got := arrayKeyMap(ids); got != want {
+		t.Errorf("arrayKeyMap = %d, want %d", got, want)
+	}
+	if got := stringKeyMap(ids); got != want {
+		t.Errorf("stringKeyMap = %d, want %d", got, want)
+	}
+
+	var r int
+	arrayAllocs := testing.AllocsPerRun(20, func() { r = arrayKeyMap(ids) })
+	stringAllocs := testing.AllocsPerRun(20, func() { r = stringKeyMap(ids) })
+	result = r
+	if stringAllocs < arrayAllocs+float64(len(ids)) {
+		t.Errorf("stringKeyMap allocs = %v, arrayKeyMap = %v; want at least one extra per key", stringAllocs, arrayAllocs)
+	}
+}
+
+func BenchmarkComparison_CacheValueType(b *testing.B) {
+	keys := make([]string, 64)
+	for i := range keys {
+		keys[i] = "session:" + strconv.Itoa(i)
+	}
+
+	b.Run("Boxed", func(b *testing.B) {
+		var sum int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			k := keys[i%len(keys)]
+			cacheSetBoxed(k, 1000+i)
+			sum += cacheGetBoxed(k).(int)
+		}
+		result = sum
+	})
+
+	b.Run("Typed", func(b *testing.B) {
+		var sum int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			k := keys[i%len(keys)]
+			cacheSetInt(k, 1000+i)
+			v, _ := cacheGetInt(k)
+			sum += v
+		}
+		result = sum
+	})
+}
+
+func TestCacheValueType(t *testing.T) {
+	cacheSetBoxed("user:1", 4242)
+	cacheSetInt("user:1", 4242)
+	if v, ok := cacheGetBoxed("user:1").(int); !ok || v != 4242 {
+		t.Errorf("cacheGetBoxed = %v, want 4242", cacheGetBoxed("user:1"))
+	}
+	if v, ok := cacheGetInt("user:1"); !ok || v != 4242 {
+		t.Errorf("cacheGetInt = %d, %v; want 4242, true", v, ok)
+	}
+	if cacheGetBoxed("missing") != nil {
+		t.Error("cacheGetBoxed of a missing key is not nil")
+	}
+	if _, ok := cacheGetInt("missing"); ok {
+		t.Error("cacheGetInt reported a missing key as present")
+	}
+
+	v := 1000
+	typed := testing.AllocsPerRun(100, func() {
+		v++
+		cacheSetInt("user:1", v)
+		cacheGetInt("user:1")
+	})
+	boxed := testing.AllocsPerRun(100, func() {
+		v++
+		cacheSetBoxed("user:1", v)
+		cacheGetBoxed("user:1")
+	})
+	if typed != 0 {
+		t.Errorf("typed cache allocs = %v, want 0", typed)
+	}
+	if boxed != 1 {
+		t.Errorf("boxed cache allocs = %v, want 1 (the box made by the store)", boxed)
+	}
+}
+
+// allOptions returns every option in declaration order.
+func allOptions() []option {
+	opts := make([]option, numOptions)
+	for i := range opts {
+		opts[i] = option(i)
+	}
+	return opts
+}
+
+func BenchmarkComparison_FlagSet(b *testing.B) {
+	opts := allOptions()
+
+	b.Run("Bit-Flags", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = setAndCheckBits(opts)
+		}
+		result = r
+	})
+
+	b.Run("Map-String-Bool", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = setAndCheckMap(opts)
+		}
+		result = r
+	})
+}
+
+func TestBitFlags(t *testing.T) {
+	var f bitFlags
+	m := make(map[string]bool)
+	for _, o := range []option{optForce, optTrace, optForce} {
+		f.Set(o)
+		m[optionNames[o]] = true
+	}
+	for o := option(0); o < numOptions; o++ {
+		if f.Has(o) != m[optionNames[o]] {
+			t.Errorf("option %s: bitFlags.Has = %v, map = %v", optionNames[o], f.Has(o), m[optionNames[o]])
+		}
+	}
+
+	for _, opts := range [][]option{nil, {optQuiet}, {optCache, optCache, optStrict}, allOptions()} {
+		if bits, mapped := setAndCheckBits(opts), setAndCheckMap(opts); bits != mapped {
+			t.Errorf("setAndCheckBits(%v) = %d, setAndCheckMap = %d", opts, bits, mapped)
+		}
+	}
+
+	opts := allOptions()
+	var r int
+	bitAllocs := testing.AllocsPerRun(100, func() { r = setAndCheckBits(opts) })
+	mapAllocs := testing.AllocsPerRun(100, func() { r = setAndCheckMap(opts) })
+	result = r
+	if bitAllocs != 0 {
+		t.Errorf("setAndCheckBits allocs = %v, want 0", bitAllocs)
+	}
+	if mapAllocs == 0 {
+		t.Error("setAndCheckMap allocs = 0, want its ten entries to outgrow the stack table")
+	}
+}
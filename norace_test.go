@@ -0,0 +1,5 @@
+//go:build !race
+
+package heapescapeanalysis
+
+const raceEnabled = false
@@ -0,0 +1,262 @@
+package heapescapeanalysis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// scanLinesBufferSize is the initial buffer size of ScanLines. The buffer
+// only grows when a single line does not fit.
+const scanLinesBufferSize = 4096
+
+// ScanLines reads r and calls fn once per line, without the line terminator
+// ("\n" or "\r\n"). A final line without a terminator is also delivered.
+//
+// All lines are read into one buffer that is reused for the whole input, so
+// ScanLines allocates once per call rather than once per line. The slice
+// passed to fn is only valid until fn returns: it is overwritten by later
+// input. Callers that need to keep a line must copy it, e.g. with
+// string(line) or bytes.Clone(line).
+//
+// ScanLines returns the first read error other than io.EOF.
+func ScanLines(r io.Reader, fn func(line []byte)) error {
+	buf := make([]byte, scanLinesBufferSize)
+	start, end := 0, 0
+	for {
+		n, err := r.Read(buf[end:])
+		end += n
+		for {
+			i := bytes.IndexByte(buf[start:end], '\n')
+			if i < 0 {
+				break
+			}
+			fn(dropCR(buf[start : start+i]))
+			start += i + 1
+		}
+		if err != nil {
+			if err != io.EOF {
+				return err
+			}
+			if start < end {
+				fn(dropCR(buf[start:end]))
+			}
+			return nil
+		}
+
+		// Move the partial line to the front; grow only if it fills the buffer
+		end = copy(buf, buf[start:end])
+		start = 0
+		if end == len(buf) {
+			buf = append(buf, make([]byte, len(buf))...)
+		}
+	}
+}
+
+func dropCR(line []byte) []byte {
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		return line[:len(line)-1]
+	}
+	return line
+}
+
+// scanLinesNaive is the common bufio.Scanner loop. Text allocates a new
+// string for every line.
+//
+//go:noinline
+func scanLinesNaive(r io.Reader, fn func(line string)) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fn(sc.Text()) // One allocation per line
+	}
+	return sc.Err()
+}
+
+// Decoding rows into interface{} versus concrete types
+//
+// Database drivers and CSV readers often hand back rows as []interface{},
+// which means every field is boxed: each int and each string header is
+// copied into its own small heap object, so allocations grow with rows times
+// columns. Decoding into a concrete Row type stores fields inline in one
+// slice of structs. Here both decoders convert the input to a string once,
+// so names are substrings of it rather than fresh copies. Malformed lines
+// are skipped.
+
+// Row is one decoded "id,name,score" record.
+type Row struct {
+	ID    int
+	Name  string
+	Score int
+}
+
+// splitRow splits an "id,name,score" line into its fields.
+func splitRow(line string) (id int, name string, score int, ok bool) {
+	idField, rest, ok1 := strings.Cut(line, ",")
+	name, scoreField, ok2 := strings.Cut(rest, ",")
+	if !ok1 || !ok2 {
+		return 0, "", 0, false
+	}
+	id, err1 := strconv.Atoi(idField)
+	score, err2 := strconv.Atoi(scoreField)
+	return id, name, score, err1 == nil && err2 == nil
+}
+
+// decodeRows returns the fields of every row, flattened, as boxed values.
+//
+//go:noinline
+func decodeRows(raw []byte) []interface{} {
+	text := string(raw)
+	fields := make([]interface{}, 0, 3*strings.Count(text, "\n")+3)
+	for line := range strings.Lines(text) {
+		id, name, score, ok := splitRow(strings.TrimRight(line, "\r\n"))
+		if !ok {
+			continue
+		}
+		fields = append(fields, id, name, score) // Three boxes per row
+	}
+	return fields
+}
+
+//go:noinline
+func decodeRowsTyped(raw []byte) []Row {
+	text := string(raw)
+	rows := make([]Row, 0, strings.Count(text, "\n")+1)
+	for line := range strings.Lines(text) {
+		id, name, score, ok := splitRow(strings.TrimRight(line, "\r\n"))
+		if !ok {
+			continue
+		}
+		rows = append(rows, Row{ID: id, Name: name, Score: score}) // Stored inline
+	}
+	return rows
+}
+
+// strings.Split versus scanning fields with a callback
+//
+// strings.Split returns every field at once, so it must allocate a []string
+// to hold them, sized by a first pass that counts separators. The fields
+// themselves are substrings of s and cost nothing. scanFields hands each
+// field to a callback instead: there is no result slice, and as long as the
+// callback does not escape (it is called, never stored), the closure can
+// live on the caller's stack too. The cost is that the caller sees one field
+// at a time; collect only the fields you need, or use strings.SplitSeq
+// (Go 1.24), which applies the same idea through an iterator.
+
+//go:noinline
+func splitViaStrings(s, sep string) []string {
+	return strings.Split(s, sep) // Allocates the result slice
+}
+
+// scanFields calls fn with each comma-separated field of s, in order. Like
+// strings.Split, an empty s yields one empty field.
+//
+//go:noinline
+func scanFields(s string, fn func(field string)) {
+	for {
+		i := strings.IndexByte(s, ',')
+		if i < 0 {
+			fn(s)
+			return
+		}
+		fn(s[:i]) // Substring of s: no allocation
+		s = s[i+1:]
+	}
+}
+
+// Parser is a whitespace tokenizer over an internal buffer that Reset
+// reuses between inputs, the parser-level version of BufferProcessor.
+//
+// Next is zero-copy: each token is a sub-slice of the internal buffer, so
+// tokenizing allocates nothing. The lifetime contract is the same as
+// ProcessData's result: a token is only valid until the next Reset, which
+// overwrites the buffer in place. A token kept past that point (stored in a
+// map, sent on a channel, appended to a result list) silently changes to
+// whatever bytes the next input put there. NextCopy returns a token the
+// caller owns, at the cost of one allocation per token; use it for tokens
+// that must outlive the input, or convert them to strings right away.
+type Parser struct {
+	buf []byte
+	pos int
+}
+
+// NewParser returns a Parser with room for 4KB of input before its buffer
+// has to grow.
+func NewParser() *Parser {
+	return &Parser{buf: make([]byte, 0, 4096)}
+}
+
+// Reset makes input the parser's current input, copying it into the
+// internal buffer. Tokens returned by Next before the call are invalidated.
+func (p *Parser) Reset(input []byte) {
+	p.buf = append(p.buf[:0], input...)
+	p.pos = 0
+}
+
+// Next returns the next space-separated token as a sub-slice of the
+// internal buffer, or false when the input is exhausted. The token is only
+// valid until the next Reset.
+func (p *Parser) Next() ([]byte, bool) {
+	for p.pos < len(p.buf) && p.buf[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos == len(p.buf) {
+		return nil, false
+	}
+	start := p.pos
+	for p.pos < len(p.buf) && p.buf[p.pos] != ' ' {
+		p.pos++
+	}
+	return p.buf[start:p.pos:p.pos], true // Capped so appends cannot overwrite the next token
+}
+
+// NextCopy is Next, returning a copy that stays valid after Reset.
+func (p *Parser) NextCopy() ([]byte, bool) {
+	tok, ok := p.Next()
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(tok), true // Escapes: owned by the caller
+}
+
+// Forwarding JSON as json.RawMessage versus decoding it
+//
+// Decoding JSON into a struct allocates for everything the document
+// contains: each string field is a new string, each array a new slice, each
+// object a new map, and encoding/json's reflection adds its own overhead on
+// top. A proxy or router that only forwards a payload, or that inspects one
+// field and passes the rest on, can skip all of that. json.RawMessage is
+// just []byte: checking the document with json.Valid and handing the bytes
+// on unchanged allocates nothing, and the output is byte-for-byte what
+// arrived, key order and whitespace included. The same idea applies inside
+// a struct: a json.RawMessage field defers decoding of that subtree until
+// something actually needs it. The returned message aliases data, so the
+// caller must not reuse data's buffer while the message is in use.
+
+// Payload is the document decoded by fullDecode.
+type Payload struct {
+	ID    int               `json:"id"`
+	Name  string            `json:"name"`
+	Tags  []string          `json:"tags"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+// passthroughRaw returns data as a json.RawMessage if it is valid JSON, and
+// nil otherwise.
+//
+//go:noinline
+func passthroughRaw(data []byte) json.RawMessage {
+	if !json.Valid(data) { // Scans without building any values
+		return nil
+	}
+	return json.RawMessage(data) // Aliases data, no copy
+}
+
+//go:noinline
+func fullDecode(data []byte) (Payload, error) {
+	var p Payload
+	err := json.Unmarshal(data, &p) // Allocates every string, slice and map
+	return p, err
+}
@@ -0,0 +1,356 @@
+package heapescapeanalysis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func benchmarkLinesInput() []byte {
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		sb.WriteString("GET /api/v1/items?page=42 HTTP/1.1 200 1532\n")
+	}
+	return []byte(sb.String())
+}
+
+func BenchmarkComparison_ScanLines(b *testing.B) {
+	input := benchmarkLinesInput()
+
+	b.Run("Reused-Buffer", func(b *testing.B) {
+		var r int
+		rd := bytes.NewReader(input)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rd.Reset(input)
+			_ = ScanLines(rd, func(line []byte) { r += len(line) })
+		}
+		result = r
+	})
+
+	b.Run("Scanner-Text", func(b *testing.B) {
+		var r int
+		rd := bytes.NewReader(input)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rd.Reset(input)
+			_ = scanLinesNaive(rd, func(line string) { r += len(line) })
+		}
+		result = r
+	})
+}
+
+func collectLines(t *testing.T, input string, wrap func(io.Reader) io.Reader) []string {
+	t.Helper()
+	var lines []string
+	err := ScanLines(wrap(strings.NewReader(input)), func(line []byte) {
+		lines = append(lines, string(line))
+	})
+	if err != nil {
+		t.Fatalf("ScanLines(%q): %v", input, err)
+	}
+	return lines
+}
+
+func TestScanLines(t *testing.T) {
+	long := strings.Repeat("x", 3*scanLinesBufferSize+7)
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"single without newline", "abc", []string{"abc"}},
+		{"single with newline", "abc\n", []string{"abc"}},
+		{"crlf", "a\r\nb\r\n", []string{"a", "b"}},
+		{"empty lines", "\n\na\n\n", []string{"", "", "a", ""}},
+		{"longer than buffer", "head\n" + long + "\ntail", []string{"head", long, "tail"}},
+	}
+	readers := map[string]func(io.Reader) io.Reader{
+		"whole":    func(r io.Reader) io.Reader { return r },
+		"one-byte": iotest.OneByteReader,
+	}
+	for _, tt := range tests {
+		for rname, wrap := range readers {
+			got := collectLines(t, tt.input, wrap)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s/%s: lines = %q, want %q", tt.name, rname, got, tt.want)
+			}
+
+			var naive []string
+			_ = scanLinesNaive(strings.NewReader(tt.input), func(line string) { naive = append(naive, line) })
+			if !slices.Equal(got, naive) {
+				t.Errorf("%s/%s: ScanLines = %q, bufio.Scanner = %q", tt.name, rname, got, naive)
+			}
+		}
+	}
+}
+
+func TestScanLinesSliceIsReused(t *testing.T) {
+	var kept, copied []byte
+	err := ScanLines(iotest.OneByteReader(strings.NewReader("first\nsecond\n")), func(line []byte) {
+		if kept == nil {
+			kept = line // Bug: retains a slice of the scan buffer
+			copied = bytes.Clone(line)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(copied) != "first" {
+		t.Errorf("copied line = %q, want %q", copied, "first")
+	}
+	if string(kept) == "first" {
+		t.Errorf("retained line = %q, want it overwritten by later input", kept)
+	}
+}
+
+func TestScanLinesError(t *testing.T) {
+	errRead := errors.New("read failed")
+	var lines []string
+	err := ScanLines(&failingReader{data: "ab\ncd", err: errRead}, func(line []byte) {
+		lines = append(lines, string(line))
+	})
+	if !errors.Is(err, errRead) {
+		t.Errorf("ScanLines error = %v, want %v", err, errRead)
+	}
+	if !slices.Equal(lines, []string{"ab"}) {
+		t.Errorf("lines before error = %q, want [ab]", lines)
+	}
+}
+
+// failingReader returns data and then err instead of io.EOF.
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+var rowsInput = []byte("1001,alice,4200\n1002,bob,3900\n1003,carol,4750\n" +
+	"1004,dave,3100\n1005,erin,4480\n1006,frank,2900\n1007,grace,5000\n" +
+	"1008,heidi,4010\n1009,ivan,3620\n1010,judy,4390\n")
+
+func BenchmarkComparison_DecodeRows(b *testing.B) {
+	b.Run("Interface-Fields", func(b *testing.B) {
+		var r []interface{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = decodeRows(rowsInput)
+		}
+		result = r
+	})
+
+	b.Run("Typed-Rows", func(b *testing.B) {
+		var r []Row
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = decodeRowsTyped(rowsInput)
+		}
+		result = r
+	})
+}
+
+func TestDecodeRows(t *testing.T) {
+	input := append([]byte("bad line\n"), rowsInput...)
+	input = append(input, "1011,ken,12"...) // No trailing newline
+	fields, rows := decodeRows(input), decodeRowsTyped(input)
+	if len(rows) != 11 || len(fields) != 3*len(rows) {
+		t.Fatalf("decoded %d rows and %d fields, want 11 and 33", len(rows), len(fields))
+	}
+	for i, row := range rows {
+		boxed := Row{ID: fields[3*i].(int), Name: fields[3*i+1].(string), Score: fields[3*i+2].(int)}
+		if row != boxed {
+			t.Errorf("row %d: typed = %+v, boxed = %+v", i, row, boxed)
+		}
+	}
+	if rows[0] != (Row{ID: 1001, Name: "alice", Score: 4200}) {
+		t.Errorf("rows[0] = %+v", rows[0])
+	}
+
+	var r []Row
+	allocs := testing.AllocsPerRun(100, func() { r = decodeRowsTyped(rowsInput) })
+	result = r
+	// The string conversion of the input and the rows slice
+	if allocs != 2 {
+		t.Errorf("decodeRowsTyped allocs = %v, want 2", allocs)
+	}
+}
+
+const csvLine = "1042,widget,blue,19.99,in-stock,warehouse-7,2024-05-01,,priority"
+
+func BenchmarkComparison_SplitFields(b *testing.B) {
+	b.Run("Strings-Split", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, f := range splitViaStrings(csvLine, ",") {
+				r += len(f)
+			}
+		}
+		result = r
+	})
+
+	b.Run("Scan-Callback", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			scanFields(csvLine, func(f string) { r += len(f) })
+		}
+		result = r
+	})
+}
+
+func TestScanFields(t *testing.T) {
+	for _, line := range []string{csvLine, "", "single", ",", "a,,b,"} {
+		var scanned []string
+		scanFields(line, func(f string) { scanned = append(scanned, f) })
+		if split := splitViaStrings(line, ","); !slices.Equal(scanned, split) {
+			t.Errorf("%q: scanFields = %q, strings.Split = %q", line, scanned, split)
+		}
+	}
+
+	var total int
+	allocs := testing.AllocsPerRun(100, func() {
+		scanFields(csvLine, func(f string) { total += len(f) })
+	})
+	if allocs != 0 {
+		t.Errorf("scanFields allocs = %v, want 0", allocs)
+	}
+}
+
+var parserInput = []byte("GET /api/v1/items?limit=10 HTTP/1.1 host example.com accept json")
+
+func BenchmarkComparison_ParserTokens(b *testing.B) {
+	b.Run("Zero-Copy", func(b *testing.B) {
+		p := NewParser()
+		var r int
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p.Reset(parserInput)
+			for tok, ok := p.Next(); ok; tok, ok = p.Next() {
+				r += len(tok)
+			}
+		}
+		result = r
+	})
+
+	b.Run("Copy", func(b *testing.B) {
+		p := NewParser()
+		var r int
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			p.Reset(parserInput)
+			for tok, ok := p.NextCopy(); ok; tok, ok = p.NextCopy() {
+				r += len(tok)
+			}
+		}
+		result = r
+	})
+}
+
+func TestParserTokens(t *testing.T) {
+	p := NewParser()
+	p.Reset([]byte("  alpha beta  gamma "))
+	var got []string
+	for tok, ok := p.Next(); ok; tok, ok = p.Next() {
+		got = append(got, string(tok))
+	}
+	if want := []string{"alpha", "beta", "gamma"}; !slices.Equal(got, want) {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+
+	p.Reset(parserInput)
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Reset(parserInput)
+		for _, ok := p.Next(); ok; _, ok = p.Next() {
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Reset and Next allocs = %v, want 0", allocs)
+	}
+}
+
+// TestParserDanglingToken shows the zero-copy hazard: a token kept across
+// Reset changes under the caller, while a copied token does not.
+func TestParserDanglingToken(t *testing.T) {
+	p := NewParser()
+	p.Reset([]byte("alpha beta"))
+	zeroCopy, _ := p.Next()
+	p.Reset([]byte("alpha beta"))
+	copied, _ := p.NextCopy()
+
+	p.Reset([]byte("omega delta"))
+
+	if string(zeroCopy) != "omega" {
+		t.Errorf("zero-copy token = %q after Reset, want %q: it aliases the reused buffer", zeroCopy, "omega")
+	}
+	if string(copied) != "alpha" {
+		t.Errorf("copied token = %q after Reset, want %q", copied, "alpha")
+	}
+}
+
+var payloadInput = []byte(`{"id": 42, "name": "order-created",
+	"tags": ["billing", "priority"], "attrs": {"region": "eu-west", "tier": "gold"}}`)
+
+func BenchmarkComparison_JSONPassthrough(b *testing.B) {
+	b.Run("Raw-Passthrough", func(b *testing.B) {
+		var r json.RawMessage
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = passthroughRaw(payloadInput)
+		}
+		result = r
+	})
+
+	b.Run("Full-Decode", func(b *testing.B) {
+		var r Payload
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r, _ = fullDecode(payloadInput)
+		}
+		result = r
+	})
+}
+
+func TestJSONPassthrough(t *testing.T) {
+	raw := passthroughRaw(payloadInput)
+	if !bytes.Equal(raw, payloadInput) {
+		t.Errorf("passthroughRaw changed the document:\n got %s\nwant %s", raw, payloadInput)
+	}
+	if passthroughRaw([]byte(`{"id": `)) != nil {
+		t.Error("passthroughRaw accepted truncated JSON")
+	}
+
+	p, err := fullDecode(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != 42 || p.Name != "order-created" || len(p.Tags) != 2 || p.Attrs["tier"] != "gold" {
+		t.Errorf("fullDecode = %+v", p)
+	}
+	if reencoded, _ := json.Marshal(p); bytes.Equal(reencoded, payloadInput) {
+		t.Error("re-encoding matched the input byte for byte; the test input should have spacing a round trip loses")
+	}
+
+	var r json.RawMessage
+	allocs := testing.AllocsPerRun(100, func() { r = passthroughRaw(payloadInput) })
+	result = r
+	if allocs != 0 {
+		t.Errorf("passthroughRaw allocs = %v, want 0", allocs)
+	}
+}
@@ -0,0 +1,432 @@
+package heapescapeanalysis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// statsPool wraps sync.Pool and counts how many Gets were served by a
+// recycled object versus a freshly constructed one.
+type statsPool struct {
+	pool sync.Pool
+	gets atomic.Int64
+	news atomic.Int64
+}
+
+func newStatsPool(newFn func() interface{}) *statsPool {
+	p := &statsPool{}
+	p.pool.New = func() interface{} {
+		p.news.Add(1)
+		return newFn()
+	}
+	return p
+}
+
+func (p *statsPool) Get() interface{} {
+	p.gets.Add(1)
+	return p.pool.Get()
+}
+
+func (p *statsPool) Put(x interface{}) {
+	p.pool.Put(x)
+}
+
+// Reset zeroes the counters without touching pooled objects.
+func (p *statsPool) Reset() {
+	p.gets.Store(0)
+	p.news.Store(0)
+}
+
+// ReuseRate returns the fraction of Gets that did not need New.
+func (p *statsPool) ReuseRate() float64 {
+	gets := p.gets.Load()
+	if gets == 0 {
+		return 0
+	}
+	return float64(gets-p.news.Load()) / float64(gets)
+}
+
+// poolReuseUnderGC allocates poolGarbageChunks chunks of poolGarbageSize bytes
+// per iteration to give the collector a reason to run. poolMemoryLimit keeps
+// the GOGC=off case from growing the heap without bound.
+const (
+	poolGarbageSize   = 1 << 20
+	poolGarbageChunks = 4
+	poolMemoryLimit   = 256 << 20
+)
+
+var poolGarbage []byte
+
+// poolReuseUnderGC runs useSyncPool/returnToPool for the given number of
+// iterations with the collector set to gcPercent (-1 disables it) and
+// returns the observed reuse rate of largeStructPool.
+//
+// sync.Pool drops its contents on every GC (keeping them for one extra cycle
+// in a victim cache), so the more often the collector runs, the more often
+// Get falls through to New. The original GC percent and memory limit are
+// restored on return.
+func poolReuseUnderGC(gcPercent, iterations int) float64 {
+	oldPercent := debug.SetGCPercent(gcPercent)
+	defer debug.SetGCPercent(oldPercent)
+	oldLimit := debug.SetMemoryLimit(poolMemoryLimit)
+	defer debug.SetMemoryLimit(oldLimit)
+
+	largeStructPool.Reset()
+	for i := 0; i < iterations; i++ {
+		obj := useSyncPool()
+		returnToPool(obj)
+		// Simulate the rest of a request allocating memory
+		for j := 0; j < poolGarbageChunks; j++ {
+			poolGarbage = make([]byte, poolGarbageSize)
+		}
+	}
+	poolGarbage = nil
+	return largeStructPool.ReuseRate()
+}
+
+// byteBufferSize is the size of the buffers in bufferPool.
+const byteBufferSize = 64 << 10
+
+// bufferPool holds *[]byte rather than []byte: putting a slice header in an
+// interface{} would allocate on every Put.
+var bufferPool = newStatsPool(func() interface{} {
+	b := make([]byte, byteBufferSize)
+	return &b
+})
+
+// bufferReuseUnderForcedGC gets a buffer from bufferPool, writes to it and
+// puts it back, iterations times, forcing cycles back-to-back collections
+// with runtime.GC every gcEvery iterations (never if gcEvery is 0). It
+// returns the pool's reuse rate.
+//
+// A single collection does not empty a sync.Pool: it moves the pooled
+// objects to a victim cache, and a Get before the next collection still
+// finds them there. Only an object that sits unused through two collections
+// is dropped, and the next Get pays for a new 64KB buffer. With one buffer
+// cycling, cycles=1 therefore keeps reuse near 100% while cycles=2 loses the
+// buffer at every forced pause: the allocation spikes users see after GC.
+func bufferReuseUnderForcedGC(iterations, gcEvery, cycles int) float64 {
+	bufferPool.Reset()
+	for i := 0; i < iterations; i++ {
+		if gcEvery > 0 && i > 0 && i%gcEvery == 0 {
+			for c := 0; c < cycles; c++ {
+				runtime.GC()
+			}
+		}
+		bp := bufferPool.Get().(*[]byte)
+		buf := *bp
+		buf[0], buf[len(buf)-1] = byte(i), byte(i)
+		bufferPool.Put(bp)
+	}
+	return bufferPool.ReuseRate()
+}
+
+// Reset-and-reuse versus reallocation
+//
+// useSyncPool resets each object with *obj = LargeStruct{} before handing it
+// out. reuseZeroedStruct isolates that pattern without the pool: one struct
+// is kept and zeroed on every call. Zeroing is not free (a memclr of the
+// whole 24KB), but a fresh allocation zeroes the same memory too and adds
+// the allocator's bookkeeping and, later, the garbage collector's work. Reuse
+// only wins while the reset is cheaper than that overhead; a struct that is
+// mostly overwritten anyway can skip the reset, while a tiny struct is often
+// cheaper to allocate than to reset and guard against aliasing.
+
+var reusedLargeStruct = &LargeStruct{}
+
+// reuseZeroedStruct returns the shared struct, zeroed. The previous result
+// is invalidated by the next call.
+//
+//go:noinline
+func reuseZeroedStruct() *LargeStruct {
+	*reusedLargeStruct = LargeStruct{} // Reset in place: no allocation
+	return reusedLargeStruct
+}
+
+//go:noinline
+func reallocStruct() *LargeStruct {
+	return &LargeStruct{} // Escapes: a new 24KB object per call
+}
+
+// FreeList is a mutex-guarded LIFO of reusable objects. Unlike sync.Pool it
+// is never cleared by the garbage collector: every object Put is handed back
+// by a later Get. The tradeoff is that idle objects stay allocated until the
+// program drops the list, whereas sync.Pool gives memory back after a GC.
+//
+// List nodes are recycled internally, so steady-state Get/Put cycles do not
+// allocate. The zero value is ready to use; New, if set, constructs objects
+// when the list is empty, otherwise Get returns new(T).
+type FreeList[T any] struct {
+	New func() *T
+
+	mu    sync.Mutex
+	head  *freeListNode[T]
+	spare *freeListNode[T]
+	size  int
+}
+
+type freeListNode[T any] struct {
+	value *T
+	next  *freeListNode[T]
+}
+
+// Get removes and returns the most recently Put object, or a new one if the
+// list is empty.
+func (l *FreeList[T]) Get() *T {
+	l.mu.Lock()
+	n := l.head
+	if n == nil {
+		l.mu.Unlock()
+		if l.New != nil {
+			return l.New()
+		}
+		return new(T)
+	}
+	l.head = n.next
+	l.size--
+	v := n.value
+	n.value = nil
+	n.next = l.spare
+	l.spare = n
+	l.mu.Unlock()
+	return v
+}
+
+// Put adds x to the list. Putting nil is a no-op.
+func (l *FreeList[T]) Put(x *T) {
+	if x == nil {
+		return
+	}
+	l.mu.Lock()
+	n := l.spare
+	if n != nil {
+		l.spare = n.next
+	} else {
+		n = &freeListNode[T]{}
+	}
+	n.value = x
+	n.next = l.head
+	l.head = n
+	l.size++
+	l.mu.Unlock()
+}
+
+// Len returns the number of objects waiting to be reused.
+func (l *FreeList[T]) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
+
+// Codec is a gob encoder and decoder joined by one reusable buffer, so
+// values encoded by the encoder are read back by its own decoder. It is
+// useful wherever a service repeatedly serializes and rebuilds values in
+// process, for example deep-copying cached objects.
+//
+// gob streams are stateful: the first value of each type carries its type
+// definition and later values only reference it. A fresh encoder/decoder
+// pair pays for that definition (and the reflection work behind it) on every
+// use; a pooled pair pays once per type. The two halves must stay in step,
+// so a Codec whose round trip fails is never reused: CodecPool.Put drops it.
+type Codec struct {
+	buf    bytes.Buffer
+	enc    *gob.Encoder
+	dec    *gob.Decoder
+	broken bool
+}
+
+func newCodec() *Codec {
+	c := &Codec{}
+	c.enc = gob.NewEncoder(&c.buf)
+	c.dec = gob.NewDecoder(&c.buf)
+	return c
+}
+
+// RoundTrip encodes src and decodes the result into dst, which must be a
+// pointer. After an error the Codec must not be used again.
+func (c *Codec) RoundTrip(src, dst interface{}) error {
+	if c.broken {
+		return errors.New("codec: reused after a failed round trip")
+	}
+	c.buf.Reset()
+	if err := c.enc.Encode(src); err != nil {
+		c.broken = true
+		return err
+	}
+	if err := c.dec.Decode(dst); err != nil {
+		c.broken = true
+		return err
+	}
+	if c.buf.Len() != 0 {
+		// The decoder did not consume the whole message; the stream is out of
+		// step and the next round trip would misread it.
+		c.broken = true
+		return errors.New("codec: unread data after decode")
+	}
+	return nil
+}
+
+// maxPooledCodecBuffer bounds the buffer a Codec may keep when it is
+// returned to the pool, so one huge value does not pin memory indefinitely.
+const maxPooledCodecBuffer = 64 << 10
+
+// CodecPool pools Codecs. The zero value is not usable; call NewCodecPool.
+type CodecPool struct {
+	pool *statsPool
+}
+
+// NewCodecPool returns an empty CodecPool.
+func NewCodecPool() *CodecPool {
+	return &CodecPool{pool: newStatsPool(func() interface{} { return newCodec() })}
+}
+
+// Get returns a Codec from the pool, creating one if none is available.
+func (p *CodecPool) Get() *Codec {
+	return p.pool.Get().(*Codec)
+}
+
+// Put returns c to the pool. Codecs that failed a round trip or grew an
+// oversized buffer are dropped instead.
+func (p *CodecPool) Put(c *Codec) {
+	if c.broken || c.buf.Cap() > maxPooledCodecBuffer {
+		return
+	}
+	c.buf.Reset()
+	p.pool.Put(c)
+}
+
+// RoundTrip runs src through a pooled Codec into dst.
+func (p *CodecPool) RoundTrip(src, dst interface{}) error {
+	c := p.Get()
+	err := c.RoundTrip(src, dst)
+	p.Put(c)
+	return err
+}
+
+// ReuseRate returns the fraction of Gets served by a pooled Codec.
+func (p *CodecPool) ReuseRate() float64 {
+	return p.pool.ReuseRate()
+}
+
+// BufioPool hands out bufio.Readers and bufio.Writers reset onto a caller's
+// stream, so a server does not allocate a fresh pair of buffers (4KB each by
+// default) for every connection it accepts. The buffers are the expensive
+// part; the Reader and Writer structs around them are reused with them.
+//
+// Callers must return every Reader and Writer with PutReader and PutWriter
+// once the stream is done, and must not use them afterwards. A Writer must
+// be flushed before it is put back: buffered data that was never flushed is
+// discarded. Put also detaches the underlying stream, so a pooled buffer
+// never keeps a closed connection reachable.
+type BufioPool struct {
+	size    int
+	readers *statsPool
+	writers *statsPool
+}
+
+// NewBufioPool returns a pool of Readers and Writers with size-byte buffers.
+func NewBufioPool(size int) *BufioPool {
+	p := &BufioPool{size: size}
+	p.readers = newStatsPool(func() interface{} { return bufio.NewReaderSize(nil, p.size) })
+	p.writers = newStatsPool(func() interface{} { return bufio.NewWriterSize(nil, p.size) })
+	return p
+}
+
+// GetReader returns a pooled Reader that reads from r.
+func (p *BufioPool) GetReader(r io.Reader) *bufio.Reader {
+	br := p.readers.Get().(*bufio.Reader)
+	br.Reset(r) // Drops anything left buffered from a previous stream
+	return br
+}
+
+// PutReader returns br to the pool.
+func (p *BufioPool) PutReader(br *bufio.Reader) {
+	br.Reset(nil)
+	p.readers.Put(br)
+}
+
+// GetWriter returns a pooled Writer that writes to w.
+func (p *BufioPool) GetWriter(w io.Writer) *bufio.Writer {
+	bw := p.writers.Get().(*bufio.Writer)
+	bw.Reset(w)
+	return bw
+}
+
+// PutWriter returns bw to the pool, discarding any unflushed data.
+func (p *BufioPool) PutWriter(bw *bufio.Writer) {
+	bw.Reset(nil)
+	p.writers.Put(bw)
+}
+
+// ReuseRate returns the fraction of Gets, readers and writers together,
+// served by a pooled object.
+func (p *BufioPool) ReuseRate() float64 {
+	gets := p.readers.gets.Load() + p.writers.gets.Load()
+	if gets == 0 {
+		return 0
+	}
+	news := p.readers.news.Load() + p.writers.news.Load()
+	return float64(gets-news) / float64(gets)
+}
+
+// Pooled response buffers
+//
+// A handler that assembles its response in a fresh bytes.Buffer allocates
+// the buffer's backing array on every request, and then reallocates it as
+// the status line, headers and body outgrow it. Because the bytes are
+// handed to an io.Writer the compiler cannot see through, none of that can
+// stay on the stack. Taking a buffer from a pool instead reuses an array
+// that has already grown to a typical response size, so a steady stream of
+// requests stops allocating for the response at all. Two rules keep this
+// safe: the buffer must not be touched, or its bytes retained, after Put,
+// and oversized buffers are dropped rather than pooled so that one large
+// response does not pin its memory for the life of the process.
+
+// maxPooledResponseBuffer bounds the buffers writeResponse returns to its
+// pool.
+const maxPooledResponseBuffer = 64 << 10
+
+var responseBufferPool = newStatsPool(func() interface{} {
+	return new(bytes.Buffer)
+})
+
+// appendResponse writes a minimal HTTP/1.1 response carrying payload to buf.
+func appendResponse(buf *bytes.Buffer, payload []byte) {
+	buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: ")
+	buf.Write(strconv.AppendInt(buf.AvailableBuffer(), int64(len(payload)), 10))
+	buf.WriteString("\r\n\r\n")
+	buf.Write(payload)
+}
+
+// writeResponse writes a response carrying payload to w, assembling it in a
+// pooled buffer.
+//
+//go:noinline
+func writeResponse(w io.Writer, payload []byte) error {
+	buf := responseBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	appendResponse(buf, payload) // Reuses the buffer's grown array
+	_, err := w.Write(buf.Bytes())
+	if buf.Cap() <= maxPooledResponseBuffer {
+		responseBufferPool.Put(buf)
+	}
+	return err
+}
+
+// writeResponseFresh is writeResponse with a new buffer per call.
+//
+//go:noinline
+func writeResponseFresh(w io.Writer, payload []byte) error {
+	var buf bytes.Buffer
+	appendResponse(&buf, payload) // Allocates and regrows the array each call
+	_, err := w.Write(buf.Bytes())
+	return err
+}
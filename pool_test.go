@@ -0,0 +1,541 @@
+package heapescapeanalysis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var gcPercents = []int{50, 100, -1}
+
+func gcPercentName(p int) string {
+	if p < 0 {
+		return "GOGC=off"
+	}
+	return "GOGC=" + strconv.Itoa(p)
+}
+
+func BenchmarkPoolUnderGOGC(b *testing.B) {
+	for _, p := range gcPercents {
+		b.Run(gcPercentName(p), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			rate := poolReuseUnderGC(p, b.N)
+			b.ReportMetric(rate*100, "%reuse")
+		})
+	}
+}
+
+func TestPoolReuseHigherWithGCOff(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops objects at random under the race detector")
+	}
+	const iterations = 500
+
+	rates := make(map[int]float64, len(gcPercents))
+	for _, p := range gcPercents {
+		rates[p] = poolReuseUnderGC(p, iterations)
+		t.Logf("%s: reuse %.2f%%", gcPercentName(p), rates[p]*100)
+	}
+	if rates[-1] <= rates[50] {
+		t.Errorf("reuse with GC off = %.4f, want > %.4f (GOGC=50)", rates[-1], rates[50])
+	}
+}
+
+func BenchmarkComparison_PoolVsFreeList(b *testing.B) {
+	b.Run("Sync-Pool", func(b *testing.B) {
+		largeStructPool.Reset()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			obj := useSyncPool()
+			returnToPool(obj)
+		}
+		b.ReportMetric(largeStructPool.ReuseRate()*100, "%reuse")
+	})
+
+	b.Run("Free-List", func(b *testing.B) {
+		var news int
+		l := &FreeList[LargeStruct]{New: func() *LargeStruct {
+			news++
+			return &LargeStruct{}
+		}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			obj := l.Get()
+			*obj = LargeStruct{}
+			l.Put(obj)
+		}
+		b.ReportMetric(float64(b.N-news)/float64(b.N)*100, "%reuse")
+	})
+}
+
+func BenchmarkComparison_PoolVsFreeListParallel(b *testing.B) {
+	b.Run("Sync-Pool", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				obj := useSyncPool()
+				returnToPool(obj)
+			}
+		})
+	})
+
+	b.Run("Free-List", func(b *testing.B) {
+		l := &FreeList[LargeStruct]{}
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				obj := l.Get()
+				*obj = LargeStruct{}
+				l.Put(obj)
+			}
+		})
+	})
+}
+
+func TestFreeListGetPut(t *testing.T) {
+	var l FreeList[int]
+	if got := l.Get(); got == nil || *got != 0 {
+		t.Fatalf("Get on empty list = %v, want new zero value", got)
+	}
+
+	a, b, c := new(int), new(int), new(int)
+	l.Put(a)
+	l.Put(b)
+	l.Put(c)
+	l.Put(nil)
+	if got := l.Len(); got != 3 {
+		t.Fatalf("Len = %d, want 3", got)
+	}
+	for i, want := range []*int{c, b, a} {
+		if got := l.Get(); got != want {
+			t.Errorf("Get #%d = %p, want %p", i, got, want)
+		}
+	}
+	if got := l.Len(); got != 0 {
+		t.Errorf("Len after draining = %d, want 0", got)
+	}
+
+	l.New = func() *int { v := 7; return &v }
+	if got := l.Get(); *got != 7 {
+		t.Errorf("Get with New = %d, want 7", *got)
+	}
+}
+
+func TestFreeListSurvivesGC(t *testing.T) {
+	var l FreeList[LargeStruct]
+	obj := &LargeStruct{}
+	l.Put(obj)
+
+	// Two cycles are enough to empty a sync.Pool, including its victim cache
+	runtime.GC()
+	runtime.GC()
+
+	if got := l.Get(); got != obj {
+		t.Errorf("Get after GC = %p, want the object put before GC (%p)", got, obj)
+	}
+}
+
+func TestFreeListNoAllocsWhenReusing(t *testing.T) {
+	var l FreeList[LargeStruct]
+	l.Put(&LargeStruct{})
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Put(l.Get())
+	})
+	if allocs != 0 {
+		t.Errorf("Get/Put cycle allocs = %v, want 0", allocs)
+	}
+}
+
+func TestFreeListConcurrent(t *testing.T) {
+	var l FreeList[int]
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				v := l.Get()
+				*v++
+				l.Put(v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for l.Len() > 0 {
+		total += *l.Get()
+	}
+	if total != 8000 {
+		t.Errorf("sum of counters = %d, want 8000", total)
+	}
+}
+
+type codecMessage struct {
+	ID   int
+	Name string
+	Tags []string
+}
+
+var sampleCodecMessage = codecMessage{ID: 42, Name: "widget", Tags: []string{"a", "b"}}
+
+// freshRoundTrip is the unpooled baseline: a new buffer, encoder and decoder
+// for every value.
+func freshRoundTrip(src, dst interface{}) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(src); err != nil {
+		return err
+	}
+	return gob.NewDecoder(&buf).Decode(dst)
+}
+
+func BenchmarkComparison_CodecRoundTrip(b *testing.B) {
+	b.Run("Fresh", func(b *testing.B) {
+		var out codecMessage
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := freshRoundTrip(&sampleCodecMessage, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+		result = out
+	})
+
+	b.Run("Pooled", func(b *testing.B) {
+		p := NewCodecPool()
+		var out codecMessage
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := p.RoundTrip(&sampleCodecMessage, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+		result = out
+		b.ReportMetric(p.ReuseRate()*100, "%reuse")
+	})
+}
+
+func TestCodecPoolRoundTrip(t *testing.T) {
+	p := NewCodecPool()
+	for i := 0; i < 3; i++ {
+		in := sampleCodecMessage
+		in.ID = i
+		var out codecMessage
+		if err := p.RoundTrip(&in, &out); err != nil {
+			t.Fatalf("round trip %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("round trip %d = %+v, want %+v", i, out, in)
+		}
+	}
+
+	// A second type on the same stream must still decode correctly
+	var n int
+	if err := p.RoundTrip(7, &n); err != nil || n != 7 {
+		t.Errorf("RoundTrip(7) = %d, %v; want 7, nil", n, err)
+	}
+}
+
+func TestCodecPoolReuse(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops objects at random under the race detector")
+	}
+	p := NewCodecPool()
+	var out codecMessage
+	for i := 0; i < 100; i++ {
+		if err := p.RoundTrip(&sampleCodecMessage, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rate := p.ReuseRate(); rate < 0.9 {
+		t.Errorf("reuse rate = %.2f, want >= 0.9", rate)
+	}
+}
+
+func TestCodecPoolDropsBrokenCodec(t *testing.T) {
+	p := NewCodecPool()
+	c := p.Get()
+	var wrong string
+	if err := c.RoundTrip(&sampleCodecMessage, &wrong); err == nil {
+		t.Fatal("decoding a struct into a string succeeded")
+	}
+	if err := c.RoundTrip(&sampleCodecMessage, &codecMessage{}); err == nil {
+		t.Error("broken codec accepted another round trip")
+	}
+	p.Put(c)
+	if got := p.Get(); got == c {
+		t.Error("Put kept a broken codec in the pool")
+	}
+}
+
+func BenchmarkComparison_ResetVsRealloc(b *testing.B) {
+	b.Run("Reset-Reuse", func(b *testing.B) {
+		var r *LargeStruct
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = reuseZeroedStruct()
+			r.data[0] = i
+		}
+		result = r
+	})
+
+	b.Run("Realloc", func(b *testing.B) {
+		var r *LargeStruct
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = reallocStruct()
+			r.data[0] = i
+		}
+		result = r
+	})
+}
+
+func TestResetVsRealloc(t *testing.T) {
+	for name, get := range map[string]func() *LargeStruct{
+		"reuseZeroedStruct": reuseZeroedStruct,
+		"reallocStruct":     reallocStruct,
+	} {
+		s := get()
+		s.data[0], s.data[len(s.data)-1] = 1, 2
+		if got := get(); *got != (LargeStruct{}) {
+			t.Errorf("%s returned a non-zero struct after the previous one was modified", name)
+		}
+	}
+
+	if reuseZeroedStruct() != reuseZeroedStruct() {
+		t.Error("reuseZeroedStruct returned different structs")
+	}
+	var r *LargeStruct
+	allocs := testing.AllocsPerRun(100, func() { r = reuseZeroedStruct() })
+	result = r
+	if allocs != 0 {
+		t.Errorf("reuseZeroedStruct allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_BufioReader(b *testing.B) {
+	const payload = "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"
+
+	b.Run("New-Reader", func(b *testing.B) {
+		var r string
+		sr := strings.NewReader(payload)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sr.Reset(payload)
+			br := bufio.NewReaderSize(sr, 4096)
+			line, _ := br.ReadSlice('\n')
+			r = string(line[:3])
+		}
+		result = r
+	})
+
+	b.Run("Pooled-Reader", func(b *testing.B) {
+		var r string
+		p := NewBufioPool(4096)
+		sr := strings.NewReader(payload)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sr.Reset(payload)
+			br := p.GetReader(sr)
+			line, _ := br.ReadSlice('\n')
+			r = string(line[:3])
+			p.PutReader(br)
+		}
+		result = r
+		b.ReportMetric(p.ReuseRate()*100, "%reuse")
+	})
+}
+
+func TestBufioPoolReaderReset(t *testing.T) {
+	p := NewBufioPool(64)
+	br := p.GetReader(strings.NewReader("first line\nleft over\n"))
+	if line, err := br.ReadString('\n'); err != nil || line != "first line\n" {
+		t.Fatalf("ReadString = %q, %v", line, err)
+	}
+	p.PutReader(br)
+
+	br = p.GetReader(strings.NewReader("second stream\n"))
+	got, err := io.ReadAll(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "second stream\n" {
+		t.Errorf("reused reader read %q, want only the new stream", got)
+	}
+	p.PutReader(br)
+}
+
+func TestBufioPoolWriterReset(t *testing.T) {
+	p := NewBufioPool(64)
+	var first, second bytes.Buffer
+
+	bw := p.GetWriter(&first)
+	bw.WriteString("flushed")
+	if err := bw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	bw.WriteString(" never flushed")
+	p.PutWriter(bw)
+
+	bw = p.GetWriter(&second)
+	bw.WriteString("second")
+	if err := bw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	p.PutWriter(bw)
+
+	if first.String() != "flushed" {
+		t.Errorf("first stream = %q, want %q", first.String(), "flushed")
+	}
+	if second.String() != "second" {
+		t.Errorf("second stream = %q, want %q: unflushed data leaked across Put", second.String(), "second")
+	}
+}
+
+func TestBufioPoolReuse(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops objects at random under the race detector")
+	}
+	p := NewBufioPool(4096)
+	for i := 0; i < 100; i++ {
+		br := p.GetReader(strings.NewReader("x"))
+		p.PutReader(br)
+		bw := p.GetWriter(io.Discard)
+		p.PutWriter(bw)
+	}
+	if rate := p.ReuseRate(); rate < 0.9 {
+		t.Errorf("reuse rate = %.2f, want >= 0.9", rate)
+	}
+
+	sr := strings.NewReader("")
+	allocs := testing.AllocsPerRun(100, func() {
+		p.PutReader(p.GetReader(sr))
+	})
+	if allocs != 0 {
+		t.Errorf("pooled GetReader/PutReader allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkBufferPoolUnderForcedGC(b *testing.B) {
+	for _, bc := range []struct {
+		name            string
+		gcEvery, cycles int
+	}{
+		{"No-GC", 0, 0},
+		{"GC-Every-100", 100, 1},
+		{"Double-GC-Every-100", 100, 2},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			rate := bufferReuseUnderForcedGC(b.N, bc.gcEvery, bc.cycles)
+			b.ReportMetric(rate*100, "%reuse")
+		})
+	}
+}
+
+func TestBufferPoolReuseDropsUnderGC(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops objects at random under the race detector")
+	}
+	const iterations, gcEvery = 200, 10
+
+	none := bufferReuseUnderForcedGC(iterations, 0, 0)
+	single := bufferReuseUnderForcedGC(iterations, gcEvery, 1)
+	double := bufferReuseUnderForcedGC(iterations, gcEvery, 2)
+	t.Logf("reuse: no GC %.2f%%, one GC %.2f%%, two GCs %.2f%%", none*100, single*100, double*100)
+
+	if none < 0.99 {
+		t.Errorf("reuse without GC = %.4f, want >= 0.99", none)
+	}
+	if single < 0.99 {
+		t.Errorf("reuse with one GC per pause = %.4f, want >= 0.99: the victim cache should keep the buffer", single)
+	}
+	// Nearly every one of the 19 pauses costs a new buffer
+	if double > single-0.05 {
+		t.Errorf("reuse with two GCs per pause = %.4f, want at least 5 points below %.4f", double, single)
+	}
+}
+
+// responsePayload is a small JSON body like a typical API response.
+var responsePayload = []byte(`{"id":1042,"status":"active","items":[{"sku":"A-17","qty":2},{"sku":"B-03","qty":1}],"total":"59.90"}`)
+
+func BenchmarkComparison_ResponseBuffer(b *testing.B) {
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			writeResponse(io.Discard, responsePayload)
+		}
+	})
+
+	b.Run("Fresh", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			writeResponseFresh(io.Discard, responsePayload)
+		}
+	})
+}
+
+func TestWriteResponse(t *testing.T) {
+	var pooled, fresh bytes.Buffer
+	if err := writeResponse(&pooled, responsePayload); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeResponseFresh(&fresh, responsePayload); err != nil {
+		t.Fatal(err)
+	}
+	want := "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: " +
+		strconv.Itoa(len(responsePayload)) + "\r\n\r\n" + string(responsePayload)
+	if pooled.String() != want || fresh.String() != want {
+		t.Errorf("responses differ from the expected one:\npooled: %q\nfresh:  %q\nwant:   %q", pooled.String(), fresh.String(), want)
+	}
+
+	if raceEnabled {
+		return
+	}
+	allocs := testing.AllocsPerRun(100, func() { writeResponse(io.Discard, responsePayload) })
+	if allocs != 0 {
+		t.Errorf("writeResponse allocs = %v, want 0 once the pool holds a buffer", allocs)
+	}
+}
+
+// TestWriteResponseConcurrent checks that concurrent requests never see
+// each other's bytes through a shared buffer.
+func TestWriteResponseConcurrent(t *testing.T) {
+	const workers, requests = 8, 200
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			payload := []byte(`{"worker":` + strconv.Itoa(w) + `}`)
+			var out bytes.Buffer
+			for i := 0; i < requests; i++ {
+				out.Reset()
+				if err := writeResponse(&out, payload); err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.HasSuffix(out.Bytes(), payload) || bytes.Count(out.Bytes(), []byte("HTTP/1.1")) != 1 {
+					errs <- fmt.Errorf("worker %d got response %q", w, out.Bytes())
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package heapescapeanalysis
+
+// raceEnabled reports whether the race detector is on. Under -race sync.Pool
+// randomly drops Puts, so reuse-rate assertions are skipped.
+const raceEnabled = true
@@ -0,0 +1,506 @@
+package heapescapeanalysis
+
+import (
+	"container/list"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// Collecting values from a pointer-returning factory
+//
+// A constructor that returns *Widget forces each Widget to the heap, so
+// collecting n of them costs n allocations plus the slice. Storing values
+// in a []Widget costs one allocation for the whole batch. When pointers are
+// really needed, allocate the values as one slice and hand out &ws[i]: still
+// a single allocation, at the cost of all widgets sharing one lifetime.
+
+// Widget is a small value type built by a factory.
+type Widget struct {
+	ID    int
+	Score int
+}
+
+//go:noinline
+func newWidget(id int) *Widget {
+	return &Widget{ID: id, Score: id * 2} // Escapes: one allocation per call
+}
+
+//go:noinline
+func makeWidget(id int) Widget {
+	return Widget{ID: id, Score: id * 2}
+}
+
+//go:noinline
+func collectFromFactory(n int) []*Widget {
+	ws := make([]*Widget, 0, n)
+	for i := 0; i < n; i++ {
+		ws = append(ws, newWidget(i))
+	}
+	return ws
+}
+
+//go:noinline
+func collectValues(n int) []Widget {
+	ws := make([]Widget, 0, n) // Single allocation for all widgets
+	for i := 0; i < n; i++ {
+		ws = append(ws, makeWidget(i))
+	}
+	return ws
+}
+
+// Sorting with sort.Slice versus slices.Sort
+//
+// sort.Slice takes the slice as interface{} plus a less closure that
+// captures xs, and builds a reflection-based swapper for the element type.
+// The boxed header, the closure and the swapper cost a couple of small
+// allocations per call, and every comparison is an indirect call.
+// slices.Sort is generic: it is instantiated for []int, compares with < and
+// swaps elements directly, so it sorts without allocating.
+
+//go:noinline
+func sortSliceClosure(xs []int) {
+	sort.Slice(xs, func(i, j int) bool {
+		return xs[i] < xs[j] // Captures xs
+	})
+}
+
+//go:noinline
+func sortGeneric(xs []int) {
+	slices.Sort(xs)
+}
+
+// Copying with a range loop versus the copy builtin
+//
+// Both versions allocate the destination exactly once. The difference is the
+// loop: copy compiles to a memmove that moves the whole block at once,
+// while a hand-written loop copies one element per iteration with a bounds
+// check unless the compiler can prove it away. Prefer copy; it is also
+// correct for overlapping slices.
+
+//go:noinline
+func copyViaRange(src []int) []int {
+	dst := make([]int, len(src))
+	for i, v := range src {
+		dst[i] = v // Element-by-element
+	}
+	return dst
+}
+
+// Growing once with slices.Grow
+//
+// Appending n elements to an empty slice reallocates whenever the length
+// reaches the capacity, roughly doubling each time, so the final slice is
+// preceded by a chain of smaller discarded arrays. slices.Grow(s, n)
+// guarantees room for n more elements with at most one allocation, after
+// which the appends only write. It does for an existing slice what
+// make([]T, 0, n) does for a new one.
+
+//go:noinline
+func appendRepeated(n int) []int {
+	var s []int
+	for i := 0; i < n; i++ {
+		s = append(s, i) // Reallocates as capacity runs out
+	}
+	return s
+}
+
+//go:noinline
+func appendWithGrow(n int) []int {
+	var s []int
+	s = slices.Grow(s, n) // One allocation for all n elements
+	for i := 0; i < n; i++ {
+		s = append(s, i)
+	}
+	return s
+}
+
+// append aliasing and the full slice expression
+//
+// append reuses the backing array whenever capacity allows. That is what
+// makes buffer reuse cheap, but it also means append(a[:2], x) writes x
+// straight into a[2] if a has room, silently changing data that another
+// slice still sees. The full slice expression a[:2:2] caps the capacity at
+// the length, so the next append has no room and must copy into a new array.
+// Use it whenever a sub-slice is handed to code that might append to it. The
+// price is the allocation that aliasing avoided.
+
+//go:noinline
+func appendAliasBug(a []int, x int) []int {
+	return append(a[:2], x) // Overwrites a[2] when cap(a) > 2
+}
+
+//go:noinline
+func appendFullSlice(a []int, x int) []int {
+	return append(a[:2:2], x) // No spare capacity: copies to a new array
+}
+
+// Taking the address of a range value versus an index
+//
+// &v in a range loop points at the loop variable, which is a copy of the
+// element. Since Go 1.22 each iteration has its own v, so every &v is a
+// distinct pointer, but each one is a separate variable moved to the heap:
+// n elements cost n allocations, and writes through the pointers never reach
+// xs. &xs[i] points into the existing backing array instead, so it allocates
+// nothing beyond the result slice and aliases the caller's data: later
+// changes to xs are visible through the pointers, and vice versa.
+
+//go:noinline
+func captureRangeValues(xs []int) []*int {
+	ptrs := make([]*int, 0, len(xs))
+	for _, v := range xs {
+		ptrs = append(ptrs, &v) // Moved to heap: one copy per element
+	}
+	return ptrs
+}
+
+//go:noinline
+func captureByIndex(xs []int) []*int {
+	ptrs := make([]*int, 0, len(xs))
+	for i := range xs {
+		ptrs = append(ptrs, &xs[i]) // Points into xs's backing array
+	}
+	return ptrs
+}
+
+// Modifying a slice element through a pointer versus by index
+//
+// p := &xs[i] looks like it should force the backing array onto the heap,
+// but escape analysis only cares where p goes. A pointer that lives for one
+// loop iteration and is only written through leaves xs exactly where the
+// caller put it, so both versions below allocate nothing. The pointer turns
+// into an escape only when it is stored somewhere longer-lived (a field, a
+// map, a returned slice, see captureByIndex), and then it pins the whole
+// backing array. For []int plain indexing is just as fast and clearer; a
+// pointer to the element pays off for struct elements, where several field
+// updates through p read better than repeating xs[i] and avoid copying the
+// element out and back.
+
+//go:noinline
+func modifyViaElementPointer(xs []int) {
+	for i := range xs {
+		p := &xs[i] // Does not escape: only written through
+		*p = *p*2 + 1
+	}
+}
+
+//go:noinline
+func modifyViaIndex(xs []int) {
+	for i := range xs {
+		xs[i] = xs[i]*2 + 1
+	}
+}
+
+// Appending into a field of a heap struct
+//
+// Appending straight into a field of a heap-resident struct makes every
+// iteration reload the slice header from memory, grow it through the usual
+// doubling steps and store the header back, with a GC write barrier on the
+// pointer whenever the collector is running. Building the batch in a local
+// slice keeps the header in registers and, because the batch size is known,
+// lets slices.Grow size the backing array once. Store the result in the
+// field when the batch is done. The escape itself is unchanged: the array
+// ends up in a heap struct either way, so it is on the heap in both versions.
+
+// recorder accumulates values in a slice field.
+type recorder struct {
+	values []int
+}
+
+//go:noinline
+func newRecorder() *recorder {
+	return &recorder{} // Escapes: returned to the caller
+}
+
+//go:noinline
+func appendToHeapField(r *recorder, n int) {
+	for i := 0; i < n; i++ {
+		r.values = append(r.values, i) // Load, grow and store the field each time
+	}
+}
+
+//go:noinline
+func appendBatchToHeapField(r *recorder, n int) {
+	batch := slices.Grow(r.values, n) // One allocation for the whole batch
+	for i := 0; i < n; i++ {
+		batch = append(batch, i)
+	}
+	r.values = batch // One store into the heap struct
+}
+
+// Converting between numeric slice types
+//
+// Go has no conversion from []int to []float64, so each conversion builds a
+// new slice, and returning it sends it to the heap: numeric code that
+// converts every batch allocates every batch. Like initSliceViaPointer,
+// intsToFloatsInto lets the caller own the destination and reuse it across
+// calls, so the conversion itself allocates nothing. Reslicing dst to
+// len(xs) up front checks the length once and lets the compiler drop the
+// bounds checks inside the loop.
+
+//go:noinline
+func intsToFloats(xs []int) []float64 {
+	out := make([]float64, len(xs)) // Escapes: returned to the caller
+	for i, x := range xs {
+		out[i] = float64(x)
+	}
+	return out
+}
+
+// intsToFloatsInto converts xs into dst, which must be at least len(xs) long.
+// Spare capacity does not count: a shorter dst panics even if it could be
+// resliced to fit.
+//
+//go:noinline
+func intsToFloatsInto(dst []float64, xs []int) {
+	if len(dst) < len(xs) {
+		panic("heapescapeanalysis: intsToFloatsInto dst is shorter than xs")
+	}
+	dst = dst[:len(xs)] // Lets the compiler drop the bounds checks below
+	for i, x := range xs {
+		dst[i] = float64(x)
+	}
+}
+
+// Exposing an internal slice versus returning a copy
+//
+// Returning a slice field hands out the header, and the header points at the
+// struct's own backing array. The call is free, but the caller can now write
+// into the struct's internals, and any later in-place change by the struct
+// shows up in slices the caller is still holding. Capping the capacity with
+// s[:len(s):len(s)] only stops appends from clobbering spare capacity; the
+// elements stay shared. A defensive copy costs an allocation and a memmove
+// per call, and in exchange the struct's invariants cannot be broken from
+// outside. Zero-copy exposure is fine for read-mostly data the caller is
+// trusted not to modify, documented as such; anything else should copy.
+
+// sampleSet owns a slice of samples.
+type sampleSet struct {
+	samples []int
+}
+
+//go:noinline
+func (s *sampleSet) exposeInternalSlice() []int {
+	return s.samples // Aliases the internal backing array
+}
+
+//go:noinline
+func (s *sampleSet) copyInternalSlice() []int {
+	return slices.Clone(s.samples) // New backing array per call
+}
+
+// A pointer-linked list versus nodes in one slice
+//
+// Building a linked list with new nodes costs one heap allocation per node,
+// and each node is a separate object the collector has to find and scan.
+// The allocator places successive nodes wherever free slots happen to be,
+// so after some churn a traversal jumps across memory and misses cache on
+// nearly every Next. Allocating the nodes as one []ListNode costs a single
+// allocation, keeps them contiguous in list order and lets the whole list
+// be freed at once. The links can still be pointers into the slice, as
+// below, or indexes, which also spare the collector from scanning them. The
+// tradeoff is that nodes can no longer be freed or moved individually.
+
+// ListNode is a singly linked list node.
+type ListNode struct {
+	Value int
+	Next  *ListNode
+}
+
+// buildLinkedList returns a list holding 0..n-1, or nil if n is 0.
+//
+//go:noinline
+func buildLinkedList(n int) *ListNode {
+	var head *ListNode
+	for i := n - 1; i >= 0; i-- {
+		head = &ListNode{Value: i, Next: head} // One allocation per node
+	}
+	return head
+}
+
+// buildSliceList returns the nodes of a list holding 0..n-1, linked in
+// order; the head is the first element.
+//
+//go:noinline
+func buildSliceList(n int) []ListNode {
+	nodes := make([]ListNode, n) // One allocation for every node
+	for i := range nodes {
+		nodes[i].Value = i
+		if i > 0 {
+			nodes[i-1].Next = &nodes[i]
+		}
+	}
+	return nodes
+}
+
+// A slice as a stack versus container/list
+//
+// container/list allocates an Element for every PushBack, each one a
+// separate heap object whose next, prev and list pointers and interface{}
+// Value the collector must trace. Because the value is an interface{},
+// pushing an int outside the runtime's small-int cache boxes it as well. A
+// slice used as a stack appends into one backing array and pops by
+// reslicing: n pushes cost at most the array's growth, or a single
+// allocation when the capacity is known, and a reused stack allocates
+// nothing at all. Linked containers only earn their cost when elements must
+// be removed from the middle through a retained handle; for LIFO and FIFO
+// use, a slice wins.
+
+// sliceStackPushPop pushes 0..n-1 onto a slice-backed stack, then pops
+// every value and appends it to dst.
+//
+//go:noinline
+func sliceStackPushPop(n int, dst []int) []int {
+	stack := make([]int, 0, n) // One allocation for all n pushes
+	for i := 0; i < n; i++ {
+		stack = append(stack, i)
+	}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		dst = append(dst, top)
+	}
+	return dst
+}
+
+// listStackPushPop is sliceStackPushPop using a container/list.
+//
+//go:noinline
+func listStackPushPop(n int, dst []int) []int {
+	stack := list.New()
+	for i := 0; i < n; i++ {
+		stack.PushBack(i) // Allocates an Element and boxes i
+	}
+	for stack.Len() > 0 {
+		top := stack.Remove(stack.Back())
+		dst = append(dst, top.(int))
+	}
+	return dst
+}
+
+// reflect.DeepEqual versus slices.Equal
+//
+// reflect.DeepEqual takes its arguments as interface{}, so both slice
+// headers are boxed on the way in, and the boxes escape into reflect, which
+// makes two small heap allocations per call. It then walks the values with
+// reflection: every element goes through reflect.Value, a kind switch and
+// bookkeeping for cyclic data, which makes it over ten times slower than a
+// plain loop. slices.Equal is generic, compiles to a direct comparison of
+// the ints, and allocates nothing. DeepEqual also differs in meaning: it
+// treats a nil slice and an empty one as different, while slices.Equal only
+// compares lengths and elements. Keep DeepEqual for tests on arbitrary
+// types, and use typed comparisons on hot paths.
+
+//go:noinline
+func deepEqualCompare(a, b []int) bool {
+	return reflect.DeepEqual(a, b) // Boxes a and b, walks them by reflection
+}
+
+//go:noinline
+func typedEqualCompare(a, b []int) bool {
+	return slices.Equal(a, b)
+}
+
+// Appending structs with a pointer field versus pointer-free structs
+//
+// One pointer field changes how a slice's backing array is treated. An
+// element type with no pointers is allocated in "noscan" memory: the
+// collector never looks inside it, however large the slice grows. Add a
+// pointer and every element must be scanned on every GC cycle, and each one
+// keeps its target alive, so a slice of a million events pins a million
+// separate objects. Building those targets is usually where the cost starts:
+// taking the address of a per-element local moves it to the heap, one
+// allocation per append. Storing the small struct inline keeps the slice a
+// single pointer-free allocation. Pointer-free types also help upstream:
+// values that never hold addresses cannot make anything they are built from
+// escape.
+
+// eventMeta is the per-event detail both event types carry.
+type eventMeta struct {
+	Code, Severity int
+}
+
+// eventWithPointer refers to its detail.
+type eventWithPointer struct {
+	ID   int
+	Meta *eventMeta
+}
+
+// eventInline stores its detail in place.
+type eventInline struct {
+	ID   int
+	Meta eventMeta
+}
+
+//go:noinline
+func appendStructWithPointer(n int) []eventWithPointer {
+	events := make([]eventWithPointer, 0, n) // Scanned by the GC
+	for i := 0; i < n; i++ {
+		meta := eventMeta{Code: i, Severity: i % 4} // Moved to heap: its address is stored
+		events = append(events, eventWithPointer{ID: i, Meta: &meta})
+	}
+	return events
+}
+
+//go:noinline
+func appendStructInline(n int) []eventInline {
+	events := make([]eventInline, 0, n) // Noscan: no pointers inside
+	for i := 0; i < n; i++ {
+		events = append(events, eventInline{ID: i, Meta: eventMeta{Code: i, Severity: i % 4}})
+	}
+	return events
+}
+
+// Ranging over large structs by value versus by index
+//
+// for _, it := range items promises a copy of each element, and for a
+// 224-byte LineItem that copy is real work. When the body only reads a few
+// fields and calls nothing, the compiler reads them straight from the slice
+// and the copy disappears. Once the body calls a function that is not
+// inlined, though, the callee might modify items, so the compiler has to
+// take the snapshot the language promises: the whole element is copied
+// before the call, every iteration, even though only two fields are used.
+// Indexing with items[i] reads the fields in place, as does taking
+// p := &items[i] when the body uses the element several times. For small
+// element types the copy is free and range by value reads better; for big
+// ones in hot loops, index.
+
+// LineItem is an order line large enough for copies to matter.
+type LineItem struct {
+	SKU        [16]byte
+	Quantity   int
+	PriceCents int
+	Discounts  [8]int
+	Attrs      [16]int
+}
+
+// lineAllowed reports whether a line with quantity q counts toward a total.
+//
+//go:noinline
+func lineAllowed(q int) bool {
+	return q > 0
+}
+
+//go:noinline
+func sumByValue(items []LineItem) int {
+	sum := 0
+	for _, it := range items { // Copies 224 bytes: it must survive the call
+		if !lineAllowed(it.Quantity) {
+			continue
+		}
+		sum += it.Quantity * it.PriceCents
+	}
+	return sum
+}
+
+//go:noinline
+func sumByIndex(items []LineItem) int {
+	sum := 0
+	for i := range items {
+		if !lineAllowed(items[i].Quantity) {
+			continue
+		}
+		sum += items[i].Quantity * items[i].PriceCents // Read in place
+	}
+	return sum
+}
@@ -0,0 +1,736 @@
+package heapescapeanalysis
+
+import (
+	"slices"
+	"testing"
+	"unsafe"
+)
+
+func BenchmarkComparison_FactoryCollection(b *testing.B) {
+	b.Run("Pointer-Factory", func(b *testing.B) {
+		var r []*Widget
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = collectFromFactory(100)
+		}
+		result = r
+	})
+
+	b.Run("Values", func(b *testing.B) {
+		var r []Widget
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = collectValues(100)
+		}
+		result = r
+	})
+}
+
+func TestCollectValues(t *testing.T) {
+	ptrs := collectFromFactory(100)
+	vals := collectValues(100)
+	if len(ptrs) != len(vals) {
+		t.Fatalf("len = %d and %d, want equal", len(ptrs), len(vals))
+	}
+	for i := range vals {
+		if *ptrs[i] != vals[i] {
+			t.Errorf("widget %d: factory = %+v, values = %+v", i, *ptrs[i], vals[i])
+		}
+	}
+
+	var r []Widget
+	allocs := testing.AllocsPerRun(100, func() {
+		r = collectValues(100)
+	})
+	result = r
+	if allocs != 1 {
+		t.Errorf("collectValues allocs = %v, want 1", allocs)
+	}
+}
+
+func unsortedInts(n int) []int {
+	xs := make([]int, n)
+	for i := range xs {
+		xs[i] = (i * 7919) % n
+	}
+	return xs
+}
+
+func BenchmarkComparison_Sort(b *testing.B) {
+	src := unsortedInts(100)
+
+	b.Run("Sort-Slice", func(b *testing.B) {
+		xs := make([]int, len(src))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			copy(xs, src)
+			sortSliceClosure(xs)
+		}
+		result = xs
+	})
+
+	b.Run("Slices-Sort", func(b *testing.B) {
+		xs := make([]int, len(src))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			copy(xs, src)
+			sortGeneric(xs)
+		}
+		result = xs
+	})
+}
+
+func TestSortVariants(t *testing.T) {
+	a, b := unsortedInts(100), unsortedInts(100)
+	sortSliceClosure(a)
+	sortGeneric(b)
+	if !slices.IsSorted(a) || !slices.Equal(a, b) {
+		t.Errorf("sortSliceClosure = %v, sortGeneric = %v", a, b)
+	}
+
+	src := unsortedInts(100)
+	xs := make([]int, len(src))
+	genericAllocs := testing.AllocsPerRun(100, func() {
+		copy(xs, src)
+		sortGeneric(xs)
+	})
+	closureAllocs := testing.AllocsPerRun(100, func() {
+		copy(xs, src)
+		sortSliceClosure(xs)
+	})
+	if genericAllocs != 0 {
+		t.Errorf("sortGeneric allocs = %v, want 0", genericAllocs)
+	}
+	if closureAllocs == 0 {
+		t.Error("sortSliceClosure allocs = 0, want the boxed slice, closure and swapper to allocate")
+	}
+}
+
+func BenchmarkComparison_CopyVariants(b *testing.B) {
+	src := unsortedInts(100)
+
+	b.Run("Range-Loop", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = copyViaRange(src)
+		}
+		result = r
+	})
+
+	b.Run("Builtin-Copy", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = copyInsteadOfNew(src)
+		}
+		result = r
+	})
+}
+
+func TestCopyVariants(t *testing.T) {
+	src := unsortedInts(100)
+	viaRange, viaCopy := copyViaRange(src), copyInsteadOfNew(src)
+	if !slices.Equal(viaRange, src) || !slices.Equal(viaCopy, src) {
+		t.Errorf("copyViaRange = %v, copyInsteadOfNew = %v, want %v", viaRange, viaCopy, src)
+	}
+	if &viaRange[0] == &src[0] || &viaCopy[0] == &src[0] {
+		t.Error("copy shares the source's backing array")
+	}
+}
+
+func BenchmarkComparison_AppendGrow(b *testing.B) {
+	b.Run("Repeated-Append", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = appendRepeated(1000)
+		}
+		result = r
+	})
+
+	b.Run("Slices-Grow", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = appendWithGrow(1000)
+		}
+		result = r
+	})
+}
+
+func TestAppendGrow(t *testing.T) {
+	repeated, grown := appendRepeated(1000), appendWithGrow(1000)
+	if !slices.Equal(repeated, grown) {
+		t.Error("appendRepeated and appendWithGrow differ")
+	}
+
+	// slices.Grow is append(s, make([]E, n)...), which the compiler turns
+	// into a single growslice. With -race that rewrite is off, so the
+	// temporary make is a second allocation: 2 instead of 1.
+	want := 1.0
+	if raceEnabled {
+		want = 2
+	}
+	var r []int
+	allocs := testing.AllocsPerRun(100, func() { r = appendWithGrow(1000) })
+	result = r
+	if allocs != want {
+		t.Errorf("appendWithGrow allocs = %v, want %v", allocs, want)
+	}
+}
+
+func BenchmarkComparison_AppendAlias(b *testing.B) {
+	a := []int{1, 2, 3, 4}
+
+	b.Run("Aliasing-Append", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = appendAliasBug(a, i)
+		}
+		result = r
+	})
+
+	b.Run("Full-Slice-Expression", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = appendFullSlice(a, i)
+		}
+		result = r
+	})
+}
+
+func TestAppendAlias(t *testing.T) {
+	a := []int{1, 2, 3, 4}
+	b := appendAliasBug(a, 99)
+	if !slices.Equal(b, []int{1, 2, 99}) {
+		t.Errorf("appendAliasBug = %v, want [1 2 99]", b)
+	}
+	if a[2] != 99 {
+		t.Errorf("a[2] = %d after appendAliasBug, want 99: the append should have overwritten it", a[2])
+	}
+
+	a = []int{1, 2, 3, 4}
+	c := appendFullSlice(a, 99)
+	if !slices.Equal(c, []int{1, 2, 99}) {
+		t.Errorf("appendFullSlice = %v, want [1 2 99]", c)
+	}
+	if !slices.Equal(a, []int{1, 2, 3, 4}) {
+		t.Errorf("a = %v after appendFullSlice, want it unchanged", a)
+	}
+	if &c[0] == &a[0] {
+		t.Error("appendFullSlice result shares a's backing array")
+	}
+}
+
+func BenchmarkComparison_CaptureRangeValues(b *testing.B) {
+	xs := make([]int, 100)
+	for i := range xs {
+		xs[i] = i
+	}
+
+	b.Run("Range-Value", func(b *testing.B) {
+		var r []*int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = captureRangeValues(xs)
+		}
+		result = r
+	})
+
+	b.Run("Index", func(b *testing.B) {
+		var r []*int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = captureByIndex(xs)
+		}
+		result = r
+	})
+}
+
+func TestCaptureRangeValues(t *testing.T) {
+	xs := []int{10, 20, 30}
+
+	byValue := captureRangeValues(xs)
+	for i, p := range byValue {
+		if *p != xs[i] {
+			t.Errorf("captureRangeValues[%d] = %d, want %d", i, *p, xs[i])
+		}
+		if p == &xs[i] {
+			t.Errorf("captureRangeValues[%d] points into xs, want a copy", i)
+		}
+	}
+	if byValue[0] == byValue[1] {
+		t.Error("captureRangeValues returned the same pointer twice; want one variable per iteration")
+	}
+
+	byIndex := captureByIndex(xs)
+	for i, p := range byIndex {
+		if p != &xs[i] {
+			t.Errorf("captureByIndex[%d] does not point at xs[%d]", i, i)
+		}
+	}
+
+	xs[0] = 99
+	if *byValue[0] != 10 {
+		t.Errorf("*captureRangeValues[0] = %d after xs[0] = 99, want 10", *byValue[0])
+	}
+	if *byIndex[0] != 99 {
+		t.Errorf("*captureByIndex[0] = %d after xs[0] = 99, want 99", *byIndex[0])
+	}
+
+	var r []*int
+	valueAllocs := testing.AllocsPerRun(100, func() { r = captureRangeValues(xs) })
+	indexAllocs := testing.AllocsPerRun(100, func() { r = captureByIndex(xs) })
+	result = r
+	if valueAllocs != float64(len(xs)+1) {
+		t.Errorf("captureRangeValues allocs = %v, want %d (one per element plus the slice)", valueAllocs, len(xs)+1)
+	}
+	if indexAllocs != 1 {
+		t.Errorf("captureByIndex allocs = %v, want 1", indexAllocs)
+	}
+}
+
+func BenchmarkComparison_ModifyElement(b *testing.B) {
+	var xs [1024]int
+
+	b.Run("Element-Pointer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			modifyViaElementPointer(xs[:])
+		}
+	})
+
+	b.Run("Index", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			modifyViaIndex(xs[:])
+		}
+	})
+}
+
+func TestModifyElement(t *testing.T) {
+	var a, b [64]int
+	for i := range a {
+		a[i], b[i] = i, i
+	}
+	modifyViaElementPointer(a[:])
+	modifyViaIndex(b[:])
+	if a != b {
+		t.Errorf("modifyViaElementPointer = %v, modifyViaIndex = %v", a, b)
+	}
+	if a[10] != 21 {
+		t.Errorf("a[10] = %d, want 21", a[10])
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		var xs [64]int
+		modifyViaElementPointer(xs[:])
+	})
+	if allocs != 0 {
+		t.Errorf("modifyViaElementPointer allocs = %v, want 0: xs should stay on the stack", allocs)
+	}
+}
+
+func BenchmarkComparison_AppendToHeapField(b *testing.B) {
+	b.Run("Field-Append", func(b *testing.B) {
+		var r *recorder
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = newRecorder()
+			appendToHeapField(r, 1000)
+		}
+		result = r
+	})
+
+	b.Run("Local-Batch", func(b *testing.B) {
+		var r *recorder
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = newRecorder()
+			appendBatchToHeapField(r, 1000)
+		}
+		result = r
+	})
+}
+
+func TestAppendToHeapField(t *testing.T) {
+	field, batch := newRecorder(), newRecorder()
+	field.values = []int{-1}
+	batch.values = []int{-1}
+	appendToHeapField(field, 1000)
+	appendBatchToHeapField(batch, 1000)
+	if len(batch.values) != 1001 || !slices.Equal(field.values, batch.values) {
+		t.Errorf("appendToHeapField and appendBatchToHeapField differ (len %d and %d)", len(field.values), len(batch.values))
+	}
+
+	want := 2.0
+	if raceEnabled {
+		want = 3 // As in TestAppendGrow, slices.Grow allocates twice under -race
+	}
+	var r *recorder
+	allocs := testing.AllocsPerRun(100, func() {
+		r = newRecorder()
+		appendBatchToHeapField(r, 1000)
+	})
+	result = r
+	if allocs != want {
+		t.Errorf("newRecorder plus appendBatchToHeapField allocs = %v, want %v", allocs, want)
+	}
+}
+
+func BenchmarkComparison_IntsToFloats(b *testing.B) {
+	xs := make([]int, 1000)
+	fillSequence(xs)
+
+	b.Run("New-Slice", func(b *testing.B) {
+		var r []float64
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = intsToFloats(xs)
+		}
+		result = r
+	})
+
+	b.Run("Reused-Destination", func(b *testing.B) {
+		dst := make([]float64, len(xs))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			intsToFloatsInto(dst, xs)
+		}
+		result = dst
+	})
+}
+
+func TestIntsToFloats(t *testing.T) {
+	xs := []int{-2, 0, 3, 1 << 40}
+	dst := make([]float64, len(xs)+2)
+	intsToFloatsInto(dst, xs)
+	if got := intsToFloats(xs); !slices.Equal(got, dst[:len(xs)]) {
+		t.Errorf("intsToFloats = %v, intsToFloatsInto = %v", got, dst[:len(xs)])
+	}
+	if dst[3] != 1<<40 {
+		t.Errorf("dst[3] = %v, want %v", dst[3], float64(1<<40))
+	}
+
+	allocs := testing.AllocsPerRun(100, func() { intsToFloatsInto(dst, xs) })
+	if allocs != 0 {
+		t.Errorf("intsToFloatsInto allocs = %v, want 0", allocs)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("intsToFloatsInto with a short dst did not panic")
+		}
+	}()
+	intsToFloatsInto(make([]float64, 1, len(xs)), xs) // Capacity is not length
+}
+
+func BenchmarkComparison_ExposeSlice(b *testing.B) {
+	s := &sampleSet{samples: make([]int, 1000)}
+	fillSequence(s.samples)
+
+	b.Run("Alias", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = s.exposeInternalSlice()
+		}
+		result = r
+	})
+
+	b.Run("Copy", func(b *testing.B) {
+		var r []int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = s.copyInternalSlice()
+		}
+		result = r
+	})
+}
+
+func TestExposeInternalSlice(t *testing.T) {
+	s := &sampleSet{samples: []int{1, 2, 3}}
+
+	c := s.copyInternalSlice()
+	c[0] = 100
+	if s.samples[0] != 1 {
+		t.Errorf("writing to the copy changed samples[0] to %d", s.samples[0])
+	}
+
+	a := s.exposeInternalSlice()
+	a[0] = 100
+	if s.samples[0] != 100 {
+		t.Errorf("writing to the alias left samples[0] = %d, want 100: the slices should share memory", s.samples[0])
+	}
+	s.samples[1] = 200
+	if a[1] != 200 {
+		t.Errorf("alias[1] = %d after an internal write, want 200", a[1])
+	}
+	if c[1] != 2 {
+		t.Errorf("copy[1] = %d after an internal write, want 2", c[1])
+	}
+
+	var r []int
+	aliasAllocs := testing.AllocsPerRun(100, func() { r = s.exposeInternalSlice() })
+	copyAllocs := testing.AllocsPerRun(100, func() { r = s.copyInternalSlice() })
+	result = r
+	if aliasAllocs != 0 {
+		t.Errorf("exposeInternalSlice allocs = %v, want 0", aliasAllocs)
+	}
+	if copyAllocs != 1 {
+		t.Errorf("copyInternalSlice allocs = %v, want 1", copyAllocs)
+	}
+}
+
+// sumList adds the values of the list starting at head.
+func sumList(head *ListNode) int {
+	sum := 0
+	for n := head; n != nil; n = n.Next {
+		sum += n.Value
+	}
+	return sum
+}
+
+func BenchmarkComparison_LinkedVsSliceList(b *testing.B) {
+	b.Run("Linked", func(b *testing.B) {
+		var r *ListNode
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = buildLinkedList(1000)
+		}
+		result = r
+	})
+
+	b.Run("Slice-Backed", func(b *testing.B) {
+		var r []ListNode
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = buildSliceList(1000)
+		}
+		result = r
+	})
+}
+
+func TestLinkedVsSliceList(t *testing.T) {
+	linked := buildLinkedList(1000)
+	nodes := buildSliceList(1000)
+	if got, want := sumList(&nodes[0]), sumList(linked); got != want || want != 999*1000/2 {
+		t.Errorf("slice list sum = %d, linked list sum = %d, want %d", got, want, 999*1000/2)
+	}
+	if buildLinkedList(0) != nil || len(buildSliceList(0)) != 0 {
+		t.Error("empty lists should have no nodes")
+	}
+
+	var head *ListNode
+	linkedAllocs := testing.AllocsPerRun(10, func() { head = buildLinkedList(1000) })
+	var r []ListNode
+	sliceAllocs := testing.AllocsPerRun(10, func() { r = buildSliceList(1000) })
+	result = head
+	result = r
+	if linkedAllocs != 1000 {
+		t.Errorf("buildLinkedList allocs = %v, want 1000", linkedAllocs)
+	}
+	if sliceAllocs != 1 {
+		t.Errorf("buildSliceList allocs = %v, want 1", sliceAllocs)
+	}
+}
+
+func BenchmarkComparison_StackPushPop(b *testing.B) {
+	b.Run("Slice", func(b *testing.B) {
+		dst := make([]int, 0, 1000)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			dst = sliceStackPushPop(1000, dst[:0])
+		}
+		result = dst
+	})
+
+	b.Run("Container-List", func(b *testing.B) {
+		dst := make([]int, 0, 1000)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			dst = listStackPushPop(1000, dst[:0])
+		}
+		result = dst
+	})
+}
+
+func TestStackPushPop(t *testing.T) {
+	fromSlice := sliceStackPushPop(1000, nil)
+	fromList := listStackPushPop(1000, nil)
+	if !slices.Equal(fromSlice, fromList) {
+		t.Fatalf("pop orders differ:\nslice: %v\nlist:  %v", fromSlice, fromList)
+	}
+	if len(fromSlice) != 1000 || fromSlice[0] != 999 || fromSlice[999] != 0 {
+		t.Errorf("pop order starts %d and ends %d, want LIFO from 999 to 0", fromSlice[0], fromSlice[len(fromSlice)-1])
+	}
+
+	dst := make([]int, 0, 1000)
+	sliceAllocs := testing.AllocsPerRun(10, func() { dst = sliceStackPushPop(1000, dst[:0]) })
+	listAllocs := testing.AllocsPerRun(10, func() { dst = listStackPushPop(1000, dst[:0]) })
+	if sliceAllocs != 1 {
+		t.Errorf("sliceStackPushPop allocs = %v, want 1", sliceAllocs)
+	}
+	if listAllocs < 1000 {
+		t.Errorf("listStackPushPop allocs = %v, want at least one per element", listAllocs)
+	}
+}
+
+func BenchmarkComparison_DeepEqual(b *testing.B) {
+	x := make([]int, 100)
+	fillSequence(x)
+	y := slices.Clone(x)
+
+	b.Run("Reflect-DeepEqual", func(b *testing.B) {
+		var r bool
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = deepEqualCompare(x, y)
+		}
+		result = r
+	})
+
+	b.Run("Slices-Equal", func(b *testing.B) {
+		var r bool
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = typedEqualCompare(x, y)
+		}
+		result = r
+	})
+}
+
+func TestDeepEqualCompare(t *testing.T) {
+	x := make([]int, 100)
+	fillSequence(x)
+	changed := slices.Clone(x)
+	changed[99]++
+	tests := []struct {
+		name string
+		a, b []int
+	}{
+		{"equal", x, slices.Clone(x)},
+		{"same backing array", x, x},
+		{"last element differs", x, changed},
+		{"different lengths", x, x[:99]},
+		{"both empty", []int{}, []int{}},
+	}
+	for _, tt := range tests {
+		if deep, typed := deepEqualCompare(tt.a, tt.b), typedEqualCompare(tt.a, tt.b); deep != typed {
+			t.Errorf("%s: deepEqualCompare = %v, typedEqualCompare = %v", tt.name, deep, typed)
+		}
+	}
+	if deepEqualCompare(nil, []int{}) || !typedEqualCompare(nil, []int{}) {
+		t.Error("nil versus empty: want DeepEqual false and slices.Equal true")
+	}
+
+	var r bool
+	y := slices.Clone(x)
+	allocs := testing.AllocsPerRun(100, func() { r = typedEqualCompare(x, y) })
+	result = r
+	if allocs != 0 {
+		t.Errorf("typedEqualCompare allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_AppendPointerStruct(b *testing.B) {
+	b.Run("Pointer-Field", func(b *testing.B) {
+		var r []eventWithPointer
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = appendStructWithPointer(1000)
+		}
+		result = r
+	})
+
+	b.Run("Value-Fields", func(b *testing.B) {
+		var r []eventInline
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = appendStructInline(1000)
+		}
+		result = r
+	})
+}
+
+func TestAppendPointerStruct(t *testing.T) {
+	withPointer := appendStructWithPointer(1000)
+	inline := appendStructInline(1000)
+	if len(withPointer) != len(inline) {
+		t.Fatalf("lengths differ: %d and %d", len(withPointer), len(inline))
+	}
+	for i := range inline {
+		if withPointer[i].ID != inline[i].ID || *withPointer[i].Meta != inline[i].Meta {
+			t.Fatalf("event %d differs: %+v versus %+v", i, *withPointer[i].Meta, inline[i].Meta)
+		}
+	}
+	if withPointer[1].Meta == withPointer[2].Meta {
+		t.Error("events share one eventMeta; each should point at its own")
+	}
+
+	var p []eventWithPointer
+	pointerAllocs := testing.AllocsPerRun(10, func() { p = appendStructWithPointer(1000) })
+	var v []eventInline
+	inlineAllocs := testing.AllocsPerRun(10, func() { v = appendStructInline(1000) })
+	result = p
+	result = v
+	if pointerAllocs != 1001 {
+		t.Errorf("appendStructWithPointer allocs = %v, want 1001 (the slice and one eventMeta per element)", pointerAllocs)
+	}
+	if inlineAllocs != 1 {
+		t.Errorf("appendStructInline allocs = %v, want 1", inlineAllocs)
+	}
+}
+
+// lineItems returns n LineItems with varying quantities and prices.
+func lineItems(n int) []LineItem {
+	items := make([]LineItem, n)
+	for i := range items {
+		items[i].Quantity = i%5 + 1
+		items[i].PriceCents = 100 + i
+	}
+	return items
+}
+
+func BenchmarkComparison_RangeLargeStruct(b *testing.B) {
+	items := lineItems(1000)
+
+	b.Run("By-Value", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = sumByValue(items)
+		}
+		result = r
+	})
+
+	b.Run("By-Index", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = sumByIndex(items)
+		}
+		result = r
+	})
+}
+
+func TestRangeLargeStruct(t *testing.T) {
+	if size := unsafe.Sizeof(LineItem{}); size != 224 {
+		t.Errorf("LineItem is %d bytes, want 224 as documented", size)
+	}
+	items := lineItems(1000)
+	want := 0
+	for i := range items {
+		want += (i%5 + 1) * (100 + i)
+	}
+	if got := sumByValue(items); got != want {
+		t.Errorf("sumByValue = %d, want %d", got, want)
+	}
+	if got := sumByIndex(items); got != want {
+		t.Errorf("sumByIndex = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,164 @@
+package heapescapeanalysis
+
+import (
+	"bytes"
+	"strings"
+	"unsafe"
+)
+
+// Interner returns a canonical instance for each distinct string so that
+// repeated equal strings share one backing array. Retaining many copies of
+// the same few values (log fields, column names, enum-like tokens) then
+// costs one allocation per distinct value instead of one per occurrence.
+//
+// An Interner created with a positive limit evicts the oldest entry once it
+// holds that many strings; evicted strings remain valid but are no longer
+// shared with later calls. An Interner is not safe for concurrent use.
+type Interner struct {
+	strs  map[string]string
+	limit int
+	order []string // Insertion order ring, used only when limit > 0
+	next  int
+}
+
+// NewInterner returns an unbounded Interner.
+func NewInterner() *Interner {
+	return &Interner{strs: make(map[string]string)}
+}
+
+// NewBoundedInterner returns an Interner that holds at most limit strings.
+func NewBoundedInterner(limit int) *Interner {
+	return &Interner{
+		strs:  make(map[string]string, limit),
+		limit: limit,
+		order: make([]string, 0, limit),
+	}
+}
+
+// Intern returns the canonical instance of s. The first time a value is
+// seen it is cloned, so a substring of a large buffer does not keep that
+// buffer alive.
+func (in *Interner) Intern(s string) string {
+	if c, ok := in.strs[s]; ok {
+		return c
+	}
+	return in.add(strings.Clone(s))
+}
+
+// InternBytes is Intern for a byte slice. Values already interned are
+// returned without allocating.
+func (in *Interner) InternBytes(b []byte) string {
+	if c, ok := in.strs[string(b)]; ok { // No allocation for the lookup
+		return c
+	}
+	return in.add(string(b))
+}
+
+func (in *Interner) add(s string) string {
+	if in.limit > 0 {
+		if len(in.order) < in.limit {
+			in.order = append(in.order, s)
+		} else {
+			delete(in.strs, in.order[in.next])
+			in.order[in.next] = s
+			in.next = (in.next + 1) % in.limit
+		}
+	}
+	in.strs[s] = s
+	return s
+}
+
+// Len returns the number of strings currently interned.
+func (in *Interner) Len() int {
+	return len(in.strs)
+}
+
+// bytes.Join versus manual preallocation
+//
+// bytes.Join already sums the part lengths and allocates the result once, so
+// both versions make exactly one allocation; any difference is in the
+// copying loop. Manual control pays off when the caller knows more than the
+// helper does: no separator to interleave, a destination buffer that can be
+// reused across calls, or extra bytes (a header, a trailing newline) to fit
+// in the same allocation.
+
+//go:noinline
+func bytesJoin(parts [][]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+//go:noinline
+func bytesManualAppend(parts [][]byte) []byte {
+	total := 0
+	for _, p := range parts {
+		total += len(p)
+	}
+	out := make([]byte, 0, total) // Exact size, single allocation
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// Strings from byte slices: copying versus unsafe zero-copy
+//
+// string(buf) must copy: strings are immutable, and buf can be modified
+// after the conversion. When the string outlives the call (returned,
+// stored, used as a map key that is inserted) the copy goes to the heap,
+// which makes this one of the most common allocations in parsers. The
+// compiler skips the copy only when it can prove the string is short-lived,
+// as in a map lookup m[string(buf)] or a comparison.
+//
+// UNSAFE: bytesToStringUnsafe shares buf's memory instead. It does not
+// allocate, but the result is only valid while buf is never written again:
+// a later write (or reusing buf for the next line) silently changes the
+// string, breaking every map key and comparison that relied on it. Use it
+// only for buffers that are immutable from that point on.
+
+//go:noinline
+func buildStringFromBytes(buf []byte) string {
+	return string(buf) // Copies: the string escapes to the caller
+}
+
+// bytesToStringUnsafe returns a string sharing buf's memory. buf must not be
+// modified afterwards.
+//
+//go:noinline
+func bytesToStringUnsafe(buf []byte) string {
+	return unsafe.String(unsafe.SliceData(buf), len(buf)) // No copy
+}
+
+// strings.Builder with and without Grow
+//
+// A strings.Builder appends into a []byte that starts empty and grows like
+// any slice, so concatenating a hundred parts reallocates the buffer several
+// times, each time copying everything written so far and leaving the old
+// array as garbage. String then hands the final buffer over as the result
+// without copying it. When the total length is known, or cheap to compute
+// as below, Grow(total) sizes the buffer once: every write fits, and the
+// returned string is the only allocation. Overestimating wastes the excess
+// bytes for the string's lifetime; underestimating only brings back some of
+// the growth steps.
+
+//go:noinline
+func builderNoGrow(parts []string) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		sb.WriteString(p) // Regrows and copies as the buffer fills
+	}
+	return sb.String()
+}
+
+//go:noinline
+func builderWithGrow(parts []string) string {
+	total := 0
+	for _, p := range parts {
+		total += len(p)
+	}
+	var sb strings.Builder
+	sb.Grow(total) // The only allocation
+	for _, p := range parts {
+		sb.WriteString(p)
+	}
+	return sb.String()
+}
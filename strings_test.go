@@ -0,0 +1,254 @@
+package heapescapeanalysis
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// tokenStream returns n byte slices cycling through distinct values.
+func tokenStream(n, distinct int) [][]byte {
+	tokens := make([][]byte, n)
+	for i := range tokens {
+		tokens[i] = []byte("status-" + strconv.Itoa(i%distinct))
+	}
+	return tokens
+}
+
+func BenchmarkComparison_Interning(b *testing.B) {
+	tokens := tokenStream(1000, 10)
+
+	b.Run("Interned", func(b *testing.B) {
+		in := NewInterner()
+		kept := make([]string, len(tokens))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j, tok := range tokens {
+				kept[j] = in.InternBytes(tok)
+			}
+		}
+		result = kept
+	})
+
+	b.Run("Not-Interned", func(b *testing.B) {
+		kept := make([]string, len(tokens))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j, tok := range tokens {
+				kept[j] = string(tok) // New copy for every occurrence
+			}
+		}
+		result = kept
+	})
+}
+
+func sameBacking(a, b string) bool {
+	return unsafe.StringData(a) == unsafe.StringData(b)
+}
+
+func TestInterner(t *testing.T) {
+	in := NewInterner()
+	a := in.Intern(string([]byte("hello")))
+	b := in.Intern(string([]byte("hello")))
+	c := in.InternBytes([]byte("hello"))
+	if a != "hello" || !sameBacking(a, b) || !sameBacking(a, c) {
+		t.Error("equal strings were not interned to one instance")
+	}
+	if d := in.Intern("world"); sameBacking(a, d) || d != "world" {
+		t.Errorf("Intern(world) = %q, want a distinct instance", d)
+	}
+	if in.Len() != 2 {
+		t.Errorf("Len = %d, want 2", in.Len())
+	}
+
+	big := "prefix-" + "token"
+	sub := in.Intern(big[7:])
+	if sameBacking(sub, big[7:]) {
+		t.Error("Intern retained the caller's backing array instead of cloning")
+	}
+}
+
+func TestInternBytesAllocs(t *testing.T) {
+	in := NewInterner()
+	tok := []byte("status-200")
+	in.InternBytes(tok)
+
+	var s string
+	allocs := testing.AllocsPerRun(100, func() {
+		s = in.InternBytes(tok)
+	})
+	result = s
+	if allocs != 0 {
+		t.Errorf("InternBytes of a known value allocs = %v, want 0", allocs)
+	}
+}
+
+func TestBoundedInterner(t *testing.T) {
+	in := NewBoundedInterner(2)
+	a := in.Intern("a")
+	in.Intern("b")
+	if !sameBacking(a, in.Intern("a")) {
+		t.Error("a was evicted before the limit was reached")
+	}
+	in.Intern("c") // Evicts a, the oldest entry
+	if in.Len() != 2 {
+		t.Errorf("Len = %d, want 2", in.Len())
+	}
+	if sameBacking(a, in.Intern(string([]byte("a")))) {
+		t.Error("a was not evicted after exceeding the limit")
+	}
+	if in.Len() != 2 {
+		t.Errorf("Len after reinserting = %d, want 2", in.Len())
+	}
+}
+
+func joinParts() [][]byte {
+	parts := make([][]byte, 10)
+	for i := range parts {
+		parts[i] = []byte("part-" + strconv.Itoa(i) + ";")
+	}
+	return parts
+}
+
+func BenchmarkComparison_BytesJoin(b *testing.B) {
+	parts := joinParts()
+
+	b.Run("Bytes-Join", func(b *testing.B) {
+		var r []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = bytesJoin(parts)
+		}
+		result = r
+	})
+
+	b.Run("Manual-Append", func(b *testing.B) {
+		var r []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = bytesManualAppend(parts)
+		}
+		result = r
+	})
+}
+
+func TestBytesJoinVariants(t *testing.T) {
+	for _, parts := range [][][]byte{nil, {}, {[]byte("one")}, joinParts()} {
+		joined, manual := bytesJoin(parts), bytesManualAppend(parts)
+		if !bytes.Equal(joined, manual) {
+			t.Errorf("bytesJoin = %q, bytesManualAppend = %q", joined, manual)
+		}
+	}
+}
+
+func BenchmarkComparison_StringFromBytes(b *testing.B) {
+	buf := []byte("content-type: application/json")
+
+	b.Run("Copy", func(b *testing.B) {
+		var r string
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = buildStringFromBytes(buf)
+		}
+		result = r
+	})
+
+	b.Run("Unsafe-Zero-Copy", func(b *testing.B) {
+		var r string
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = bytesToStringUnsafe(buf)
+		}
+		result = r
+	})
+}
+
+func TestStringFromBytes(t *testing.T) {
+	buf := []byte("content-type")
+	copied, shared := buildStringFromBytes(buf), bytesToStringUnsafe(buf)
+	if copied != shared || copied != "content-type" {
+		t.Fatalf("buildStringFromBytes = %q, bytesToStringUnsafe = %q", copied, shared)
+	}
+	if bytesToStringUnsafe(nil) != "" {
+		t.Error("bytesToStringUnsafe(nil) is not empty")
+	}
+
+	// The read-only contract: writing to buf after the conversion changes
+	// the unsafe string but not the copy.
+	buf[0] = 'C'
+	if copied != "content-type" {
+		t.Errorf("copied string changed to %q after buf was modified", copied)
+	}
+	if shared != "Content-type" {
+		t.Errorf("unsafe string = %q after buf[0] = 'C', want it to share buf's memory", shared)
+	}
+
+	var r string
+	safe := testing.AllocsPerRun(100, func() { r = buildStringFromBytes(buf) })
+	zeroCopy := testing.AllocsPerRun(100, func() { r = bytesToStringUnsafe(buf) })
+	result = r
+	if safe != 1 {
+		t.Errorf("buildStringFromBytes allocs = %v, want 1", safe)
+	}
+	if zeroCopy != 0 {
+		t.Errorf("bytesToStringUnsafe allocs = %v, want 0", zeroCopy)
+	}
+}
+
+// builderParts returns n short strings of varying length.
+func builderParts(n int) []string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = "field" + strconv.Itoa(i) + ";"
+	}
+	return parts
+}
+
+func BenchmarkComparison_BuilderGrow(b *testing.B) {
+	parts := builderParts(100)
+
+	b.Run("No-Grow", func(b *testing.B) {
+		var r string
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = builderNoGrow(parts)
+		}
+		result = r
+	})
+
+	b.Run("With-Grow", func(b *testing.B) {
+		var r string
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = builderWithGrow(parts)
+		}
+		result = r
+	})
+}
+
+func TestBuilderGrow(t *testing.T) {
+	parts := builderParts(100)
+	want := strings.Join(parts, "")
+	if got := builderNoGrow(parts); got != want {
+		t.Errorf("builderNoGrow = %q, want %q", got, want)
+	}
+	if got := builderWithGrow(parts); got != want {
+		t.Errorf("builderWithGrow = %q, want %q", got, want)
+	}
+	if builderWithGrow(nil) != "" {
+		t.Error("builderWithGrow(nil) is not empty")
+	}
+
+	var r string
+	noGrow := testing.AllocsPerRun(100, func() { r = builderNoGrow(parts) })
+	withGrow := testing.AllocsPerRun(100, func() { r = builderWithGrow(parts) })
+	result = r
+	if withGrow != 1 {
+		t.Errorf("builderWithGrow allocs = %v, want 1", withGrow)
+	}
+	if noGrow <= withGrow {
+		t.Errorf("builderNoGrow allocs = %v, want more than builderWithGrow's %v", noGrow, withGrow)
+	}
+}
@@ -0,0 +1,189 @@
+package heapescapeanalysis
+
+// Anonymous structs with pointer fields
+//
+// Escape is transitive: returning a struct by value is free, but if one of
+// its fields holds the address of a local, that local must outlive the
+// function and is moved to the heap. Anonymous struct types behave exactly
+// like named ones here; the pointer field is what matters.
+
+//go:noinline
+func returnAnonStructWithPointer() struct{ P *int } {
+	x := 42 // Moved to heap: its address leaves through P
+	return struct{ P *int }{P: &x}
+}
+
+//go:noinline
+func returnAnonStructWithValue() struct{ V int } {
+	x := 42
+	return struct{ V int }{V: x} // Copied into the result
+}
+
+// Value receivers: small versus large types
+//
+// A value receiver is an argument like any other: each call copies the
+// whole receiver. For a two-int struct that copy fits in registers and is
+// free. For LargeStruct it is 24KB moved per call, so a loop calling a
+// value-receiver method spends its time in memmove rather than in the
+// method. The copy lives in the caller's frame, so it does not allocate, but
+// it makes that frame 24KB larger and forces the goroutine's stack to grow
+// (by copying) the first time it is called; if the method lets its
+// receiver's address escape, the copy is moved to the heap on every call.
+// Large types should use pointer receivers, and mixing value and pointer
+// receivers on one type is best avoided, so in practice the whole type
+// switches to pointer receivers.
+
+// pair is a small type for which a value receiver is the right choice.
+type pair struct {
+	A, B int
+}
+
+//go:noinline
+func (p pair) sum() int { return p.A + p.B } // Copies 16 bytes
+
+//go:noinline
+func (s LargeStruct) firstByValue() int { return s.data[0] } // Copies 24KB
+
+//go:noinline
+func (s *LargeStruct) firstByPointer() int { return s.data[0] } // Copies a pointer
+
+//go:noinline
+func valueReceiverSmall(p pair, n int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += p.sum()
+	}
+	return total
+}
+
+//go:noinline
+func valueReceiverLarge(s *LargeStruct, n int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += s.firstByValue() // *s copied on every call
+	}
+	return total
+}
+
+//go:noinline
+func pointerReceiverLarge(s *LargeStruct, n int) int {
+	total := 0
+	for i := 0; i < n; i++ {
+		total += s.firstByPointer()
+	}
+	return total
+}
+
+// The stack size limit for local variables
+//
+// Escape analysis is not the only reason a local ends up on the heap: the
+// compiler also refuses to put very large variables in a stack frame. The
+// limit is a constant in the compiler (ir.MaxStackVarSize), not a runtime
+// setting, and it is 128KB for explicitly declared variables such as s below;
+// values created implicitly, by new, &T{} or make, have a lower 64KB limit
+// (ir.MaxImplicitStackVarSize). A declared local of exactly 128KB stays on
+// the stack, and one 8 bytes larger is moved to the heap with the reason
+// "too large for stack", even though it never escapes. These are
+// implementation details (the declared-variable limit used to be far larger)
+// and can change with any release, so TestStackSizeLimit checks them with
+// RunEscapeAnalysis instead of assuming them. LargeStruct, at 24KB, is far
+// below the limit, which is why returnLargeValue allocates nothing.
+
+// underLimitStruct is exactly as large as a declared stack variable may be.
+type underLimitStruct struct {
+	data [128 * 1024 / 8]int
+}
+
+// overLimitStruct is one word larger than underLimitStruct.
+type overLimitStruct struct {
+	data [128*1024/8 + 1]int
+}
+
+//go:noinline
+func returnJustUnderLimit(n int) underLimitStruct {
+	var s underLimitStruct // 128KB: stays on the stack
+	s.data[0] = n
+	return s
+}
+
+//go:noinline
+func returnJustOverLimit(n int) overLimitStruct {
+	var s overLimitStruct // 128KB + 8: moved to heap, too large for stack
+	s.data[0] = n
+	return s
+}
+
+// Multiple return values versus a result struct
+//
+// Go's register ABI returns results in registers: on amd64, up to nine
+// integer and fifteen floating-point words before anything goes through the
+// stack. Three ints come back in three registers, and a three-int struct
+// is decomposed into its fields and returned in the very same registers, so
+// multipleReturns and resultStructReturn compile to nearly identical code.
+// Neither builds anything on the heap; a struct is only allocated when its
+// address escapes, not because it is returned. Choose between them for
+// readability: a named struct documents the fields and is easier to extend.
+
+// Result groups the values multipleReturns returns separately.
+type Result struct {
+	A, B, C int
+}
+
+//go:noinline
+func multipleReturns() (int, int, int) {
+	a, b, c := 1, 2, 3
+	return a * 2, b * 3, c * 4 // Three result registers
+}
+
+//go:noinline
+func resultStructReturn() Result {
+	r := Result{A: 1, B: 2, C: 3}
+	r.A *= 2
+	r.B *= 3
+	r.C *= 4
+	return r // The same three registers, field by field
+}
+
+// Returning a struct that holds a slice versus one that holds an array
+//
+// Returning a struct by value copies its fields to the caller, and for a
+// slice field that copy is only the header. The backing array the header
+// points to is still the one make created in the callee's frame, so it has
+// to outlive the call and escapes to the heap, even for a small constant
+// size. The struct itself stays off the heap; the escape is transitive
+// through the field. A fixed-size array field is part of the struct's own
+// memory, so returning it copies the elements and nothing outlives the
+// frame: the whole struct can live on the caller's stack. The tradeoff is a
+// fixed capacity and a larger copy, which is a good deal for a handful of
+// elements and a poor one for thousands (see the stack size limit above).
+
+// holderSize is the number of values both holders carry.
+const holderSize = 16
+
+// Holder keeps its values in a slice.
+type Holder struct {
+	Values []int
+}
+
+// ArrayHolder keeps its values in a fixed-size array.
+type ArrayHolder struct {
+	Values [holderSize]int
+}
+
+//go:noinline
+func returnStructWithSlice() Holder {
+	h := Holder{Values: make([]int, holderSize)} // Backing array escapes with the header
+	for i := range h.Values {
+		h.Values[i] = i
+	}
+	return h
+}
+
+//go:noinline
+func returnStructWithArray() ArrayHolder {
+	var h ArrayHolder // Elements copied out with the struct
+	for i := range h.Values {
+		h.Values[i] = i
+	}
+	return h
+}
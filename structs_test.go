@@ -0,0 +1,268 @@
+package heapescapeanalysis
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func BenchmarkComparison_AnonStructField(b *testing.B) {
+	b.Run("Pointer-Field", func(b *testing.B) {
+		var r struct{ P *int }
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = returnAnonStructWithPointer()
+		}
+		result = r
+	})
+
+	b.Run("Value-Field", func(b *testing.B) {
+		var r struct{ V int }
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = returnAnonStructWithValue()
+		}
+		result = r
+	})
+}
+
+func TestAnonStructField(t *testing.T) {
+	if p := returnAnonStructWithPointer(); p.P == nil || *p.P != 42 {
+		t.Errorf("returnAnonStructWithPointer() = %+v, want P pointing at 42", p)
+	}
+
+	var r struct{ V int }
+	allocs := testing.AllocsPerRun(100, func() {
+		r = returnAnonStructWithValue()
+	})
+	if r.V != 42 {
+		t.Errorf("returnAnonStructWithValue().V = %d, want 42", r.V)
+	}
+	if allocs != 0 {
+		t.Errorf("returnAnonStructWithValue allocs = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkComparison_ValueReceiverSize(b *testing.B) {
+	s := &LargeStruct{}
+	s.data[0] = 1
+
+	b.Run("Small-Value", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = valueReceiverSmall(pair{A: 1, B: 2}, 100)
+		}
+		result = r
+	})
+
+	b.Run("Large-Value", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = valueReceiverLarge(s, 100)
+		}
+		result = r
+	})
+
+	b.Run("Large-Pointer", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = pointerReceiverLarge(s, 100)
+		}
+		result = r
+	})
+}
+
+func TestValueReceiverSize(t *testing.T) {
+	s := &LargeStruct{}
+	s.data[0] = 3
+	if got := valueReceiverSmall(pair{A: 1, B: 2}, 10); got != 30 {
+		t.Errorf("valueReceiverSmall = %d, want 30", got)
+	}
+	if byValue, byPointer := valueReceiverLarge(s, 10), pointerReceiverLarge(s, 10); byValue != 30 || byPointer != 30 {
+		t.Errorf("valueReceiverLarge = %d, pointerReceiverLarge = %d, want 30", byValue, byPointer)
+	}
+
+	var r int
+	allocs := testing.AllocsPerRun(10, func() { r = valueReceiverLarge(s, 10) })
+	result = r
+	if allocs != 0 {
+		t.Errorf("valueReceiverLarge allocs = %v, want 0: the copies stay on the stack", allocs)
+	}
+}
+
+func BenchmarkComparison_StackSizeLimit(b *testing.B) {
+	b.Run("Just-Under", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = returnJustUnderLimit(i).data[0]
+		}
+		result = r
+	})
+
+	b.Run("Just-Over", func(b *testing.B) {
+		var r int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = returnJustOverLimit(i).data[0]
+		}
+		result = r
+	})
+}
+
+func TestStackSizeLimit(t *testing.T) {
+	if got := unsafe.Sizeof(underLimitStruct{}); got != 128*1024 {
+		t.Fatalf("underLimitStruct is %d bytes, want 128KB", got)
+	}
+	if got := returnJustOverLimit(5).data[0]; got != 5 {
+		t.Errorf("returnJustOverLimit(5).data[0] = %d, want 5", got)
+	}
+
+	var r int
+	n := 0
+	under := testing.AllocsPerRun(10, func() { n++; r = returnJustUnderLimit(n).data[0] })
+	over := testing.AllocsPerRun(10, func() { n++; r = returnJustOverLimit(n).data[0] })
+	result = r
+	if under != 0 {
+		t.Errorf("returnJustUnderLimit allocs = %v, want 0", under)
+	}
+	if over != 1 {
+		t.Errorf("returnJustOverLimit allocs = %v, want 1", over)
+	}
+
+	if testing.Short() {
+		return
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	notes, err := RunEscapeAnalysis(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	movedToHeap := func(fn string) bool {
+		for _, n := range notesForFunc(notes, fn) {
+			if n.Message == "moved to heap: s" {
+				return true
+			}
+		}
+		return false
+	}
+	if movedToHeap("returnJustUnderLimit") {
+		t.Error("returnJustUnderLimit: s moved to heap; the limit is now below 128KB")
+	}
+	if !movedToHeap("returnJustOverLimit") {
+		t.Error("returnJustOverLimit: s not moved to heap; the limit is now above 128KB")
+	}
+}
+
+func BenchmarkComparison_MultipleReturns(b *testing.B) {
+	b.Run("Multiple-Returns", func(b *testing.B) {
+		var sum int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			a, b, c := multipleReturns()
+			sum += a + b + c
+		}
+		result = sum
+	})
+
+	b.Run("Result-Struct", func(b *testing.B) {
+		var sum int
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r := resultStructReturn()
+			sum += r.A + r.B + r.C
+		}
+		result = sum
+	})
+}
+
+func TestMultipleReturns(t *testing.T) {
+	a, b, c := multipleReturns()
+	if r := resultStructReturn(); r != (Result{A: a, B: b, C: c}) || r != (Result{A: 2, B: 6, C: 12}) {
+		t.Errorf("resultStructReturn = %+v, multipleReturns = %d, %d, %d; want 2, 6, 12", r, a, b, c)
+	}
+
+	var sum int
+	tupleAllocs := testing.AllocsPerRun(100, func() {
+		a, b, c := multipleReturns()
+		sum += a + b + c
+	})
+	structAllocs := testing.AllocsPerRun(100, func() {
+		r := resultStructReturn()
+		sum += r.A + r.B + r.C
+	})
+	result = sum
+	if tupleAllocs != 0 || structAllocs != 0 {
+		t.Errorf("multipleReturns allocs = %v, resultStructReturn allocs = %v, want 0 for both", tupleAllocs, structAllocs)
+	}
+}
+
+func BenchmarkComparison_StructWithSlice(b *testing.B) {
+	b.Run("Slice-Field", func(b *testing.B) {
+		var r Holder
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = returnStructWithSlice()
+		}
+		result = r
+	})
+
+	b.Run("Array-Field", func(b *testing.B) {
+		var r ArrayHolder
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r = returnStructWithArray()
+		}
+		result = r
+	})
+}
+
+func TestStructWithSlice(t *testing.T) {
+	s, a := returnStructWithSlice(), returnStructWithArray()
+	if !slices.Equal(s.Values, a.Values[:]) {
+		t.Errorf("slice holder %v, array holder %v", s.Values, a.Values)
+	}
+
+	var sum int
+	sliceAllocs := testing.AllocsPerRun(100, func() { sum += returnStructWithSlice().Values[1] })
+	arrayAllocs := testing.AllocsPerRun(100, func() { sum += returnStructWithArray().Values[1] })
+	result = sum
+	if sliceAllocs != 1 {
+		t.Errorf("returnStructWithSlice allocs = %v, want 1", sliceAllocs)
+	}
+	if arrayAllocs != 0 {
+		t.Errorf("returnStructWithArray allocs = %v, want 0", arrayAllocs)
+	}
+
+	if testing.Short() {
+		return
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	notes, err := RunEscapeAnalysis(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backingEscapes bool
+	for _, n := range notesForFunc(notes, "returnStructWithSlice") {
+		if v, ok := escapedValue(n.Message); ok && strings.HasPrefix(v, "make([]int") {
+			backingEscapes = true
+		}
+	}
+	if !backingEscapes {
+		t.Error("returnStructWithSlice: no note that the make'd backing array escapes")
+	}
+	for _, n := range notesForFunc(notes, "returnStructWithArray") {
+		if v, ok := escapedValue(n.Message); ok {
+			t.Errorf("returnStructWithArray: %s escapes, want nothing on the heap", v)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Package mutexcopy holds the mutex-copy bug demonstrated by
+// captureMutexByValue. It lives under testdata because go vet rejects it,
+// which is the point: TestCaptureMutexByValueIsFlagged runs vet on it.
+package mutexcopy
+
+import "sync"
+
+type guardedCounter struct {
+	mu sync.Mutex
+	n  int
+}
+
+// captureMutexByValue returns an increment function that locks a copy of
+// c's mutex. Calls of one returned function share that copy and exclude each
+// other, but they do not exclude code that locks c.mu, nor the functions
+// returned by other calls, each of which has its own copy. c.n is therefore
+// not protected.
+func captureMutexByValue(c *guardedCounter) func() {
+	mu := c.mu // BUG: copies the lock
+	return func() {
+		mu.Lock()
+		c.n++
+		mu.Unlock()
+	}
+}